@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// tablePattern matches relations by schema and name. Either part may use the
+// wildcards of path.Match, e.g. auth.* for every table in the auth schema.
+type tablePattern struct {
+	schema string
+	table  string
+}
+
+// parseTablePatterns parses a comma-separated list of "schema.table"
+// patterns. A pattern without a schema refers to the public schema. As in
+// SQL, unquoted names are folded to lower case and quoted names are not.
+func parseTablePatterns(value string) ([]tablePattern, error) {
+	var patterns []tablePattern
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		schema, table, err := splitTableName(part)
+		if err != nil {
+			return nil, err
+		}
+		p := tablePattern{schema: patternName(schema), table: patternName(table)}
+		// Reject malformed patterns up front; Match only reports them
+		// when a name is matched against them.
+		if _, err := path.Match(p.schema, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %q: %w", part, err)
+		}
+		if _, err := path.Match(p.table, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %q: %w", part, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// patternName unquotes a quoted pattern part and folds an unquoted one.
+func patternName(name string) string {
+	if ident, err := parseIdentifier(name); err == nil && strings.HasPrefix(name, `"`) {
+		return ident
+	}
+	return strings.ToLower(name)
+}
+
+func (p tablePattern) matches(r relation) bool {
+	schemaOK, _ := path.Match(p.schema, r.Schema)
+	tableOK, _ := path.Match(p.table, r.Name)
+	return schemaOK && tableOK
+}
+
+func matchesAny(patterns []tablePattern, r relation) bool {
+	for _, p := range patterns {
+		if p.matches(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// relation is a table read by a query plan
+type relation struct {
+	Schema string
+	Name   string
+}
+
+func (r relation) String() string {
+	return r.Schema + "." + r.Name
+}
+
+// planNode is the part of an EXPLAIN (VERBOSE, FORMAT JSON) plan node that
+// names the relation it scans and the expressions it outputs.
+type planNode struct {
+	RelationName string     `json:"Relation Name"`
+	Schema       string     `json:"Schema"`
+	Alias        string     `json:"Alias"`
+	Output       []string   `json:"Output"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// parsePlan decodes raw, an EXPLAIN (VERBOSE, FORMAT JSON) plan, and
+// returns its top node.
+func parsePlan(raw []byte) (planNode, error) {
+	var plans []struct {
+		Plan planNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return planNode{}, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return planNode{}, fmt.Errorf("failed to parse plan: empty")
+	}
+	return plans[0].Plan, nil
+}
+
+// walk calls fn with n and every node below it.
+func (n planNode) walk(fn func(planNode)) {
+	fn(n)
+	for _, child := range n.Plans {
+		child.walk(fn)
+	}
+}
+
+// queryPlan returns the EXPLAIN (VERBOSE, FORMAT JSON) output for query.
+func (s *PostgresServer) queryPlan(ctx context.Context, query string, args ...interface{}) ([]byte, error) {
+	var raw []byte
+	err := s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, "EXPLAIN (VERBOSE, FORMAT JSON) "+query, args...).Scan(&raw)
+	})
+	return raw, err
+}
+
+// queryRelations plans query and returns the tables its plan scans. Reading
+// the plan instead of the SQL text sees through views, CTEs and subqueries:
+// a view shows up as the tables it reads.
+func (s *PostgresServer) queryRelations(ctx context.Context, query string, args ...interface{}) ([]relation, error) {
+	raw, err := s.queryPlan(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return planRelations(raw)
+}
+
+// planRelations returns the tables scanned by raw, an EXPLAIN (VERBOSE,
+// FORMAT JSON) plan.
+func planRelations(raw []byte) ([]relation, error) {
+	plan, err := parsePlan(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var relations []relation
+	plan.walk(func(n planNode) {
+		if n.RelationName != "" {
+			relations = append(relations, relation{Schema: n.Schema, Name: n.RelationName})
+		}
+	})
+	return relations, nil
+}
+
+// uncheckedFunctionPrefixes are the name prefixes of built-in functions
+// that read data a plan does not show: they run SQL passed as text (e.g.
+// query_to_xml), read a table by name (table_to_xml), or read files and
+// large objects.
+var uncheckedFunctionPrefixes = []string{
+	"query_to_xml", "cursor_to_xml", "table_to_xml", "schema_to_xml", "database_to_xml",
+	"ts_stat", "pg_read_", "pg_ls_", "pg_stat_file", "lo_", "loread", "dblink",
+}
+
+// checkTableAccess rejects query when it reads a table outside
+// DB_ALLOW_TABLES (if set) or matched by DB_DENY_TABLES. Tables read by
+// functions the query calls are not visible in its plan, so a query calling
+// a function that can read tables, i.e. any function outside pg_catalog and
+// information_schema or a built-in that runs SQL or reads files (see
+// uncheckedFunctionPrefixes), is rejected outright.
+func (s *PostgresServer) checkTableAccess(ctx context.Context, query string, args ...interface{}) error {
+	if len(s.opts.AllowTables) == 0 && len(s.opts.DenyTables) == 0 {
+		return nil
+	}
+
+	raw, err := s.queryPlan(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("query rejected: could not check the tables it reads: %s", s.errorText(err))
+	}
+	relations, err := planRelations(raw)
+	if err != nil {
+		return fmt.Errorf("query rejected: could not check the tables it reads: %s", err)
+	}
+	if err := s.checkFunctionCalls(ctx, query, raw); err != nil {
+		return err
+	}
+	for _, r := range relations {
+		if len(s.opts.AllowTables) > 0 && !matchesAny(s.opts.AllowTables, r) {
+			return fmt.Errorf("query rejected: table %s is not in the tables accessible through this server (DB_ALLOW_TABLES)", r)
+		}
+		if matchesAny(s.opts.DenyTables, r) {
+			return fmt.Errorf("query rejected: table %s is not accessible through this server (DB_DENY_TABLES)", r)
+		}
+	}
+	return nil
+}
+
+// checkFunctionCalls rejects query, planned as raw, when it calls a function
+// that may read tables its plan does not show. Calls are taken from the SQL
+// text and from the plan's expressions, which also show the functions views
+// call.
+func (s *PostgresServer) checkFunctionCalls(ctx context.Context, query string, raw []byte) error {
+	names := calledFunctions(query)
+	var plan interface{}
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return fmt.Errorf("query rejected: failed to parse plan: %w", err)
+	}
+	for _, text := range planStrings(plan) {
+		names = append(names, calledFunctions(text)...)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	rows, err := s.queryContext(ctx, `
+        SELECT DISTINCT n.nspname, p.proname
+        FROM pg_catalog.pg_proc p
+        JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+        WHERE p.proname = ANY ($1)
+        ORDER BY 1, 2
+    `, names)
+	if err != nil {
+		return fmt.Errorf("query rejected: could not check the functions it calls: %s", s.errorText(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return fmt.Errorf("query rejected: could not check the functions it calls: %w", err)
+		}
+		if !readsUncheckedData(schema, name) {
+			continue
+		}
+		return fmt.Errorf("query rejected: function %s.%s may read tables that DB_ALLOW_TABLES and DB_DENY_TABLES cannot check", schema, name)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query rejected: could not check the functions it calls: %s", s.errorText(err))
+	}
+	return nil
+}
+
+// readsUncheckedData reports whether the function schema.name may read
+// tables without them showing up in the calling query's plan. Overloads are
+// not told apart: a name is rejected if any function with it may.
+func readsUncheckedData(schema, name string) bool {
+	if schema != "pg_catalog" && schema != "information_schema" {
+		return true
+	}
+	for _, prefix := range uncheckedFunctionPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// planStrings returns every string value in a decoded JSON plan, e.g. the
+// Output and Filter expressions of its nodes.
+func planStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var texts []string
+		for _, item := range v {
+			texts = append(texts, planStrings(item)...)
+		}
+		return texts
+	case map[string]interface{}:
+		var texts []string
+		for _, item := range v {
+			texts = append(texts, planStrings(item)...)
+		}
+		return texts
+	default:
+		return nil
+	}
+}
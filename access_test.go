@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReadsUncheckedData(t *testing.T) {
+	tests := []struct {
+		schema, name string
+		want         bool
+	}{
+		{"pg_catalog", "lower", false},
+		{"pg_catalog", "xpath", false},
+		{"information_schema", "_pg_expandarray", false},
+		{"pg_catalog", "query_to_xml", true},
+		{"pg_catalog", "table_to_xmlschema", true},
+		{"pg_catalog", "pg_read_file", true},
+		{"public", "dblink", true},
+		{"public", "lower", true},
+	}
+	for _, tt := range tests {
+		if got := readsUncheckedData(tt.schema, tt.name); got != tt.want {
+			t.Errorf("readsUncheckedData(%q, %q) = %v, want %v", tt.schema, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTableAccessFunctionCalls(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".tokens (secret text)",
+		"CREATE TABLE "+schema+".reports (total int)",
+		"CREATE FUNCTION "+schema+".read_tokens() RETURNS SETOF text LANGUAGE sql AS 'SELECT secret FROM "+schema+".tokens'",
+		"CREATE VIEW "+schema+".innocent AS SELECT * FROM "+schema+".read_tokens()",
+	)
+
+	for name, opts := range map[string]ServerOptions{
+		"deny list":  {DenyTables: []tablePattern{{schema: schema, table: "tokens"}}},
+		"allow list": {AllowTables: []tablePattern{{schema: schema, table: "report*"}}},
+	} {
+		s.opts = opts
+		for _, query := range []string{
+			"SELECT query_to_xml('select * from " + schema + ".tokens', true, false, '')",
+			"SELECT xpath('/row', query_to_xml('select * from " + schema + ".tokens', true, false, ''))",
+			"SELECT table_to_xml('" + schema + ".tokens', true, false, '')",
+			"SELECT * FROM " + schema + ".read_tokens()",
+			"SELECT * FROM " + schema + ".innocent",
+		} {
+			if err := s.checkTableAccess(context.Background(), query); err == nil || !strings.Contains(err.Error(), "query rejected") {
+				t.Errorf("%s: checkTableAccess(%q) = %v, want it rejected", name, query, err)
+			}
+		}
+
+		if err := s.checkTableAccess(context.Background(), "SELECT lower('X'), count(*) FROM "+schema+".reports"); err != nil {
+			t.Errorf("%s: built-in functions on an allowed table rejected: %v", name, err)
+		}
+	}
+}
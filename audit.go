@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// auditQueryParams are the tool parameters that carry caller SQL.
+var auditQueryParams = []string{"query", "query_a", "query_b"}
+
+// auditEntry is one line of the DB_AUDIT_LOG file
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	Tool string    `json:"tool"`
+	// SQL is the caller SQL the tool was given, if any, and Statements
+	// every statement the call ran, with its bound parameters.
+	SQL        []string    `json:"sql,omitempty"`
+	Statements []Statement `json:"statements"`
+	Rows       *int        `json:"rows,omitempty"`
+	DurationMS float64     `json:"duration_ms"`
+	Success    bool        `json:"success"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// auditStatementsKey is the context key of the auditStatements of a tool
+// call.
+type auditStatementsKey struct{}
+
+// auditStatements collects the statements a tool call runs.
+type auditStatements struct {
+	mu           sync.Mutex
+	redactParams bool
+	statements   []Statement
+}
+
+func (a *auditStatements) add(sql string, args []interface{}) {
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		if a.redactParams && arg != nil {
+			arg = maskToken
+		}
+		params[i] = arg
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.statements = append(a.statements, Statement{SQL: sql, Params: params})
+}
+
+func (a *auditStatements) list() []Statement {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Statement{}, a.statements...)
+}
+
+// auditTracer records every statement run on a connection in the
+// auditStatements of the tool call whose context it runs under. It sees
+// the SQL the tools generate as well as caller SQL, however they run it.
+type auditTracer struct{}
+
+func (auditTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if statements, ok := ctx.Value(auditStatementsKey{}).(*auditStatements); ok {
+		statements.add(data.SQL, data.Args)
+	}
+	return ctx
+}
+
+func (auditTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+// auditLog appends JSON lines to the DB_AUDIT_LOG file. Each entry is
+// written straight to the file, without buffering, so nothing is lost if
+// the server stops.
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &auditLog{file: file}, nil
+}
+
+func (a *auditLog) write(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.file.Write(line)
+	return err
+}
+
+func (a *auditLog) Close() error {
+	return a.file.Close()
+}
+
+func closeAuditLog(audit *auditLog) {
+	if audit != nil {
+		audit.Close()
+	}
+}
+
+// auditMiddleware records every tool call in the audit log with the
+// statements it ran, whether it succeeded or not. It runs inside
+// loggingMiddleware and shares its row count.
+func (s *PostgresServer) auditMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.audit == nil {
+			return next(ctx, req)
+		}
+
+		var queries []string
+		for _, param := range auditQueryParams {
+			if query := req.GetString(param, ""); query != "" {
+				queries = append(queries, query)
+			}
+		}
+		statements := &auditStatements{redactParams: s.opts.AuditRedactParams}
+		ctx = context.WithValue(ctx, auditStatementsKey{}, statements)
+
+		rows, ok := ctx.Value(rowCountKey{}).(*int)
+		if !ok {
+			rows = new(int)
+			*rows = -1
+			ctx = context.WithValue(ctx, rowCountKey{}, rows)
+		}
+
+		start := time.Now()
+		result, err := next(ctx, req)
+
+		entry := auditEntry{
+			Time:       start.UTC(),
+			Tool:       req.Params.Name,
+			SQL:        queries,
+			Statements: statements.list(),
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			Success:    err == nil && (result == nil || !result.IsError),
+		}
+		if *rows >= 0 {
+			n := *rows
+			entry.Rows = &n
+		}
+		switch {
+		case err != nil:
+			entry.Error = err.Error()
+		case result != nil && result.IsError:
+			entry.Error = resultText(result)
+		}
+		if auditErr := s.audit.write(entry); auditErr != nil {
+			slog.ErrorContext(ctx, "failed to write audit log", "error", auditErr)
+		}
+		return result, err
+	}
+}
+
+// resultText returns the text content of a tool result.
+func resultText(result *mcp.CallToolResult) string {
+	var text string
+	for _, content := range result.Content {
+		if t, ok := content.(mcp.TextContent); ok {
+			text += t.Text
+		}
+	}
+	return text
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// readAuditLog returns the entries of the audit log at path.
+func readAuditLog(t *testing.T, path string) []auditEntry {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// callAudited calls handler as the tool name through auditMiddleware.
+func callAudited(t *testing.T, s *PostgresServer, name string, handler server.ToolHandlerFunc, args map[string]interface{}) {
+	t.Helper()
+	var req mcp.CallToolRequest
+	req.Params.Name = name
+	req.Params.Arguments = args
+	s.auditMiddleware(handler)(context.Background(), req)
+}
+
+func TestAuditMiddlewareRecordsStatements(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		audit, err := openAuditLog(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &PostgresServer{opts: ServerOptions{AuditRedactParams: redact}, audit: audit}
+
+		// A tool without caller SQL is audited with what it ran.
+		callAudited(t, s, "iterate_table", func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			auditTracer{}.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{
+				SQL:  `SELECT * FROM "public"."users" WHERE "id" > $1 ORDER BY "id" LIMIT 100`,
+				Args: []interface{}{int64(42)},
+			})
+			return mcp.NewToolResultText("{}"), nil
+		}, map[string]interface{}{"table": "users", "key_column": "id", "after": 42})
+		audit.Close()
+
+		entries := readAuditLog(t, path)
+		if len(entries) != 1 {
+			t.Fatalf("got %d audit entries, want 1", len(entries))
+		}
+		entry := entries[0]
+		if entry.Tool != "iterate_table" || !entry.Success || len(entry.SQL) != 0 || len(entry.Statements) != 1 {
+			t.Fatalf("entry = %+v, want the iterate_table call and its statement", entry)
+		}
+		want := "42"
+		if redact {
+			want = `"***"`
+		}
+		if params, _ := json.Marshal(entry.Statements[0].Params); string(params) != "["+want+"]" {
+			t.Errorf("redact=%v: params = %s, want [%s]", redact, params, want)
+		}
+	}
+
+	// Statements run outside a tool call are not recorded anywhere.
+	auditTracer{}.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+}
+
+func TestAuditLogsGeneratedSQL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	s := newTestServer(t, ServerOptions{AuditLogPath: path})
+	schema := testSchema(t, s)
+	table := schema + ".items"
+	mustExec(t, s,
+		"CREATE TABLE "+table+" (id int PRIMARY KEY, label text)",
+		"INSERT INTO "+table+" SELECT g, 'item ' || g FROM generate_series(1, 5) g",
+	)
+
+	calls := []struct {
+		name    string
+		handler server.ToolHandlerFunc
+		args    map[string]interface{}
+		sql     string
+		param   string
+	}{
+		{"sample_rows", s.SampleRows, map[string]interface{}{"schema": schema, "table": "items"}, "LIMIT 10", ""},
+		{"iterate_table", s.IterateTable, map[string]interface{}{"table": table, "key_column": "id", "after": 3}, `"id" > $1`, "3"},
+		{"changes_since", s.ChangesSince, map[string]interface{}{"table": table, "cursor_column": "id", "since": 4}, `"id" > $1`, "4"},
+		{"value_counts", s.ValueCounts, map[string]interface{}{"table": table, "column": "label"}, "GROUP BY", ""},
+		{"column_stats", s.ColumnStats, map[string]interface{}{"schema": schema, "table": "items", "column": "label"}, "count(DISTINCT", ""},
+		{"postgres_query_params", s.ExecuteQueryParams, map[string]interface{}{"query": "SELECT label FROM " + table + " WHERE id = $1", "params": []interface{}{2}}, "WHERE id = $1", "2"},
+		{"postgres_query", s.ExecuteQuery, map[string]interface{}{"query": "SELECT nope FROM " + table}, "SELECT nope", ""},
+	}
+	for _, call := range calls {
+		callAudited(t, s, call.name, call.handler, call.args)
+	}
+
+	entries := readAuditLog(t, path)
+	if len(entries) != len(calls) {
+		t.Fatalf("got %d audit entries, want %d", len(entries), len(calls))
+	}
+	for i, call := range calls {
+		entry := entries[i]
+		if entry.Tool != call.name {
+			t.Errorf("entry %d is for %s, want %s", i, entry.Tool, call.name)
+			continue
+		}
+		found := false
+		for _, stmt := range entry.Statements {
+			params, _ := json.Marshal(stmt.Params)
+			if strings.Contains(stmt.SQL, call.sql) && (call.param == "" || strings.Contains(string(params), call.param)) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: statements %+v, want one containing %q with param %q", call.name, entry.Statements, call.sql, call.param)
+		}
+	}
+
+	if last := entries[len(entries)-1]; last.Success || last.Error == "" {
+		t.Errorf("failed postgres_query entry = %+v, want it marked failed", last)
+	}
+}
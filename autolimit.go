@@ -0,0 +1,88 @@
+package main
+
+import "fmt"
+
+// aggregateFunctions are the aggregates that make a SELECT without GROUP BY
+// return a single row.
+var aggregateFunctions = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+	"array_agg": true, "string_agg": true, "json_agg": true, "jsonb_agg": true,
+	"json_object_agg": true, "jsonb_object_agg": true, "bool_and": true,
+	"bool_or": true, "every": true, "bit_and": true, "bit_or": true,
+	"stddev": true, "stddev_pop": true, "stddev_samp": true, "variance": true,
+	"var_pop": true, "var_samp": true, "percentile_cont": true,
+	"percentile_disc": true, "mode": true,
+}
+
+// needsDefaultLimit reports whether DB_DEFAULT_LIMIT should be applied to
+// query: its top-level SELECT has no LIMIT or FETCH of its own and is not an
+// aggregate-only query, which returns a single row anyway. LIMIT inside
+// subqueries, CTEs, strings or comments does not count.
+func needsDefaultLimit(query string) bool {
+	tokens := topLevelTokens(query)
+
+	start := -1
+	for i, token := range tokens {
+		if token == "select" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return false
+	}
+	tokens = tokens[start+1:]
+
+	for _, token := range tokens {
+		if token == "limit" || token == "fetch" {
+			return false
+		}
+	}
+	return !isAggregateOnly(tokens)
+}
+
+// isAggregateOnly reports whether the tokens following a top-level SELECT
+// describe a query that aggregates all its rows into one: its select list
+// calls an aggregate and there is no GROUP BY, window or set operation.
+func isAggregateOnly(tokens []string) bool {
+	aggregate := false
+	inSelectList := true
+	for i, token := range tokens {
+		switch token {
+		case "from":
+			inSelectList = false
+		case "group":
+			// WITHIN GROUP belongs to an ordered-set aggregate call.
+			if i == 0 || tokens[i-1] != "within" {
+				return false
+			}
+		case "over", "window", "union", "intersect", "except":
+			return false
+		default:
+			if inSelectList && aggregateFunctions[token] && i+1 < len(tokens) && tokens[i+1] == "(" {
+				aggregate = true
+			}
+		}
+	}
+	return aggregate
+}
+
+// withDefaultLimit wraps query so that it returns at most limit+1 rows; the
+// extra row tells the caller that the limit cut the result off.
+func withDefaultLimit(query string, limit int) string {
+	return fmt.Sprintf("SELECT * FROM (%s) _sub LIMIT %d", trimTerminator(query), limit+1)
+}
+
+// withPage wraps query to return the rows after the first offset, at most
+// limit+1 of them (all when limit is zero); the extra row tells the caller
+// that another page follows.
+func withPage(query string, limit, offset int) string {
+	paged := fmt.Sprintf("SELECT * FROM (%s) _page", trimTerminator(query))
+	if limit > 0 {
+		paged += fmt.Sprintf(" LIMIT %d", limit+1)
+	}
+	if offset > 0 {
+		paged += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return paged
+}
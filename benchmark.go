@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Run counts accepted by benchmark_query
+const (
+	defaultBenchmarkRuns = 5
+	maxBenchmarkRuns     = 50
+)
+
+// BenchmarkResult summarizes the timed runs of benchmark_query, in milliseconds
+type BenchmarkResult struct {
+	Runs   int     `json:"runs"`
+	Rows   int     `json:"rows"`
+	MinMS  float64 `json:"min_ms"`
+	MaxMS  float64 `json:"max_ms"`
+	MeanMS float64 `json:"mean_ms"`
+	P95MS  float64 `json:"p95_ms"`
+}
+
+func (s *PostgresServer) BenchmarkQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query'"), nil
+	}
+
+	runs := req.GetInt("runs", defaultBenchmarkRuns)
+	if runs < 1 || runs > maxBenchmarkRuns {
+		return mcp.NewToolResultError(fmt.Sprintf("runs must be between 1 and %d", maxBenchmarkRuns)), nil
+	}
+
+	if err := s.isSafeQuery(query); err != nil {
+		return nil, fmt.Errorf("unsafe query: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, query); err != nil {
+		return nil, err
+	}
+
+	// The first run warms caches and is not counted.
+	var rowCount int
+	durations := make([]float64, 0, runs)
+	for i := 0; i <= runs; i++ {
+		n, elapsed, err := s.drainQuery(ctx, query)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Query failed: %s", s.errorText(err))), nil
+		}
+		if i == 0 {
+			continue
+		}
+		rowCount = n
+		durations = append(durations, float64(elapsed.Microseconds())/1000)
+	}
+
+	response, _ := json.Marshal(benchmarkStats(durations, rowCount))
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// benchmarkStats summarizes the run durations, in milliseconds, of a query
+// that returned rows rows. durations must not be empty.
+func benchmarkStats(durations []float64, rows int) BenchmarkResult {
+	sorted := append([]float64(nil), durations...)
+	sort.Float64s(sorted)
+	var total float64
+	for _, d := range sorted {
+		total += d
+	}
+	p95 := sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+
+	return BenchmarkResult{
+		Runs:   len(sorted),
+		Rows:   rows,
+		MinMS:  sorted[0],
+		MaxMS:  sorted[len(sorted)-1],
+		MeanMS: total / float64(len(sorted)),
+		P95MS:  p95,
+	}
+}
+
+// drainQuery runs query in a read-only transaction and reads every row
+// without keeping any of them, returning the number of rows and how long
+// running and reading the query took. BEGIN and ROLLBACK are not timed.
+func (s *PostgresServer) drainQuery(ctx context.Context, query string) (int, time.Duration, error) {
+	tx, err := s.beginReadOnlyTx(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	start := time.Now()
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	elapsed := time.Since(start)
+	return n, elapsed, rows.Err()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBenchmarkStats(t *testing.T) {
+	durations := make([]float64, 0, 20)
+	for i := 20; i >= 1; i-- {
+		durations = append(durations, float64(i))
+	}
+
+	got := benchmarkStats(durations, 3)
+	want := BenchmarkResult{Runs: 20, Rows: 3, MinMS: 1, MaxMS: 20, MeanMS: 10.5, P95MS: 19}
+	if got != want {
+		t.Errorf("benchmarkStats() = %+v, want %+v", got, want)
+	}
+	if durations[0] != 20 {
+		t.Error("benchmarkStats() reordered its input")
+	}
+
+	if got := benchmarkStats([]float64{4.2}, 0); got.MinMS != 4.2 || got.P95MS != 4.2 || got.MeanMS != 4.2 {
+		t.Errorf("single run: %+v, want every statistic 4.2", got)
+	}
+}
+
+func TestBenchmarkQuery(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	var got BenchmarkResult
+	callToolJSON(t, s.BenchmarkQuery, map[string]interface{}{
+		"query": "SELECT g, pg_sleep(0.001) FROM generate_series(1, 3) g",
+		"runs":  7,
+	}, &got)
+
+	if got.Runs != 7 || got.Rows != 3 {
+		t.Errorf("got %d runs of %d rows, want 7 runs of 3 rows", got.Runs, got.Rows)
+	}
+	if got.MinMS < 3 || got.MinMS > got.MeanMS || got.MeanMS > got.MaxMS || got.P95MS < got.MinMS || got.P95MS > got.MaxMS {
+		t.Errorf("inconsistent statistics: %+v", got)
+	}
+}
+
+func TestBenchmarkQueryRejectsRuns(t *testing.T) {
+	s := &PostgresServer{}
+	for _, runs := range []int{0, maxBenchmarkRuns + 1} {
+		text, isError := callTool(t, s.BenchmarkQuery, map[string]interface{}{"query": "SELECT 1", "runs": runs})
+		if !isError || !strings.Contains(text, "runs must be between") {
+			t.Errorf("runs %d: got %q, want a range error", runs, text)
+		}
+	}
+}
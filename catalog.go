@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func (s *PostgresServer) TablesWithoutPK(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schema := req.GetString("schema", defaultSchema)
+	if result, err := s.requireSchema(ctx, schema); result != nil || err != nil {
+		return result, err
+	}
+
+	rows, err := s.queryContext(ctx, `
+        SELECT c.relname
+        FROM pg_catalog.pg_class c
+        JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+        LEFT JOIN pg_catalog.pg_constraint con
+               ON con.conrelid = c.oid AND con.contype = 'p'
+        WHERE n.nspname = $1
+          AND c.relkind IN ('r', 'p')
+          AND con.oid IS NULL
+        ORDER BY c.relname
+    `, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables without primary key: %w", err)
+	}
+	defer rows.Close()
+
+	tables := make([]string, 0)
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tables without primary key: %w", err)
+	}
+
+	response, _ := json.Marshal(tables)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) ForeignTables(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rows, err := s.queryContext(ctx, `
+        SELECT ft.foreign_table_schema AS schema,
+               ft.foreign_table_name AS table_name,
+               ft.foreign_server_name AS server,
+               w.fdwname AS foreign_data_wrapper,
+               array_to_string(srv.srvoptions, ', ') AS server_options
+        FROM information_schema.foreign_tables ft
+        JOIN pg_catalog.pg_foreign_server srv ON srv.srvname = ft.foreign_server_name
+        JOIN pg_catalog.pg_foreign_data_wrapper w ON w.oid = srv.srvfdw
+        ORDER BY ft.foreign_table_schema, ft.foreign_table_name
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foreign tables: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result.Rows)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// IndexInfo describes an index
+type IndexInfo struct {
+	Name       string   `json:"name"`
+	Table      string   `json:"table"`
+	Columns    []string `json:"columns"`
+	Unique     bool     `json:"unique"`
+	Primary    bool     `json:"primary"`
+	Method     string   `json:"method"`
+	Definition string   `json:"definition"`
+}
+
+func (s *PostgresServer) ListIndexes(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schema, table, err := resolveTableName(req.GetString("schema", defaultSchema), req.GetString("table", ""))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Columns are rendered with pg_get_indexdef so expression indexes show
+	// their expression rather than a column number.
+	rows, err := s.queryContext(ctx, `
+        SELECT i.relname, t.relname,
+               ARRAY(SELECT pg_catalog.pg_get_indexdef(ix.indexrelid, k, true)
+                     FROM generate_series(1, ix.indnatts) k ORDER BY k),
+               ix.indisunique, ix.indisprimary, am.amname,
+               pg_catalog.pg_get_indexdef(ix.indexrelid)
+        FROM pg_catalog.pg_index ix
+        JOIN pg_catalog.pg_class i ON i.oid = ix.indexrelid
+        JOIN pg_catalog.pg_class t ON t.oid = ix.indrelid
+        JOIN pg_catalog.pg_namespace n ON n.oid = t.relnamespace
+        JOIN pg_catalog.pg_am am ON am.oid = i.relam
+        WHERE n.nspname = $1 AND ($2 = '' OR t.relname = $2)
+        ORDER BY t.relname, i.relname
+    `, schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer rows.Close()
+
+	// The type map decodes the column array; it is not safe for concurrent use.
+	typeMap := pgtype.NewMap()
+	indexes := make([]IndexInfo, 0)
+	for rows.Next() {
+		var idx IndexInfo
+		if err := rows.Scan(&idx.Name, &idx.Table, typeMap.SQLScanner(&idx.Columns), &idx.Unique, &idx.Primary, &idx.Method, &idx.Definition); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	response, _ := json.Marshal(indexes)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// ForeignKey is one column pair of a foreign key constraint. Composite keys
+// produce one entry per column, in key order.
+type ForeignKey struct {
+	Constraint       string `json:"constraint"`
+	SourceSchema     string `json:"source_schema"`
+	SourceTable      string `json:"source_table"`
+	SourceColumn     string `json:"source_column"`
+	ReferencedSchema string `json:"referenced_schema"`
+	ReferencedTable  string `json:"referenced_table"`
+	ReferencedColumn string `json:"referenced_column"`
+}
+
+func (s *PostgresServer) ListForeignKeys(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schema, table, err := resolveTableName(req.GetString("schema", defaultSchema), req.GetString("table", ""))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	keys, err := s.foreignKeys(ctx, "tc.table_schema = $1 AND ($2 = '' OR tc.table_name = $2)", schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foreign keys: %w", err)
+	}
+
+	response, _ := json.Marshal(keys)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) FindReferencingTables(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	schema, table, err := resolveTableName(req.GetString("schema", defaultSchema), table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	keys, err := s.foreignKeys(ctx, "ref.table_schema = $1 AND ref.table_name = $2", schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find referencing tables: %w", err)
+	}
+
+	response, _ := json.Marshal(keys)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// foreignKeys returns the foreign key column pairs matching filter, a
+// condition on the referencing constraint (tc), its columns (kcu) or the
+// referenced columns (ref) that takes schema and table as $1 and $2.
+func (s *PostgresServer) foreignKeys(ctx context.Context, filter, schema, table string) ([]ForeignKey, error) {
+	// constraint_column_usage cannot tell which referenced column pairs with
+	// which source column of a composite key, so the referenced side is
+	// matched through the unique constraint's key_column_usage by position.
+	rows, err := s.queryContext(ctx, `
+        SELECT tc.constraint_name,
+               kcu.table_schema, kcu.table_name, kcu.column_name,
+               ref.table_schema, ref.table_name, ref.column_name
+        FROM information_schema.table_constraints tc
+        JOIN information_schema.key_column_usage kcu
+             ON kcu.constraint_schema = tc.constraint_schema
+            AND kcu.constraint_name = tc.constraint_name
+        JOIN information_schema.referential_constraints rc
+             ON rc.constraint_schema = tc.constraint_schema
+            AND rc.constraint_name = tc.constraint_name
+        JOIN information_schema.key_column_usage ref
+             ON ref.constraint_schema = rc.unique_constraint_schema
+            AND ref.constraint_name = rc.unique_constraint_name
+            AND ref.ordinal_position = kcu.position_in_unique_constraint
+        WHERE tc.constraint_type = 'FOREIGN KEY'
+          AND `+filter+`
+        ORDER BY kcu.table_schema, kcu.table_name, tc.constraint_name, kcu.ordinal_position
+    `, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]ForeignKey, 0)
+	for rows.Next() {
+		var fk ForeignKey
+		if err := rows.Scan(&fk.Constraint, &fk.SourceSchema, &fk.SourceTable, &fk.SourceColumn,
+			&fk.ReferencedSchema, &fk.ReferencedTable, &fk.ReferencedColumn); err != nil {
+			return nil, err
+		}
+		keys = append(keys, fk)
+	}
+	return keys, rows.Err()
+}
+
+func (s *PostgresServer) ListViews(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schema := req.GetString("schema", defaultSchema)
+	name := req.GetString("name", "")
+
+	if name != "" {
+		var definition sql.NullString
+		err := s.withConnRetry(ctx, func() error {
+			return s.db.QueryRowContext(ctx, `
+                SELECT view_definition
+                FROM information_schema.views
+                WHERE table_schema = $1 AND table_name = $2
+            `, schema, name).Scan(&definition)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return mcp.NewToolResultError(fmt.Sprintf("View %s.%s does not exist", schema, name)), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get view definition: %w", err)
+		}
+
+		// information_schema only shows the definition to the view's owner.
+		response, _ := json.Marshal(map[string]interface{}{
+			"name":       name,
+			"schema":     schema,
+			"definition": definition.String,
+		})
+		return mcp.NewToolResultText(string(response)), nil
+	}
+
+	rows, err := s.queryContext(ctx, `
+        SELECT table_name
+        FROM information_schema.views
+        WHERE table_schema = $1
+        ORDER BY table_name
+    `, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list views: %w", err)
+	}
+	defer rows.Close()
+
+	views := make([]string, 0)
+	for rows.Next() {
+		var view string
+		if err := rows.Scan(&view); err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list views: %w", err)
+	}
+
+	response, _ := json.Marshal(views)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) ListSchemas(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	includeSystem := req.GetBool("include_system", false)
+
+	rows, err := s.queryContext(ctx, `
+        SELECT schema_name AS schema, schema_owner AS owner
+        FROM information_schema.schemata
+        WHERE $1 OR (schema_name NOT IN ('pg_catalog', 'information_schema')
+                     AND schema_name NOT LIKE 'pg\_toast%'
+                     AND schema_name NOT LIKE 'pg\_temp\_%')
+        ORDER BY schema_name
+    `, includeSystem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result.Rows)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) ListExtensions(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rows, err := s.queryContext(ctx, `
+        SELECT e.extname AS name,
+               e.extversion AS installed_version,
+               a.default_version
+        FROM pg_catalog.pg_extension e
+        LEFT JOIN pg_catalog.pg_available_extensions a ON a.name = e.extname
+        ORDER BY e.extname
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extensions: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result.Rows)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) SearchColumns(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pattern, err := req.RequireString("pattern")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'pattern'"), nil
+	}
+	schema := req.GetString("schema", "")
+
+	rows, err := s.queryContext(ctx, `
+        SELECT table_schema AS schema, table_name, column_name, data_type
+        FROM information_schema.columns
+        WHERE column_name ILIKE $1
+          AND ($2 = '' OR table_schema = $2)
+          AND table_schema NOT IN ('pg_catalog', 'information_schema')
+        ORDER BY table_schema, table_name, column_name
+    `, pattern, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search columns: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result.Rows)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) ListSequences(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schema := req.GetString("schema", "")
+
+	// pg_sequences.last_value is NULL until nextval has been called, or
+	// when the current user may not read the sequence.
+	rows, err := s.queryContext(ctx, `
+        SELECT s.sequence_schema AS schema,
+               s.sequence_name AS name,
+               s.data_type,
+               s.start_value::numeric AS start_value,
+               s.increment::numeric AS increment,
+               ps.last_value AS current_value
+        FROM information_schema.sequences s
+        LEFT JOIN pg_catalog.pg_sequences ps
+               ON ps.schemaname = s.sequence_schema AND ps.sequencename = s.sequence_name
+        WHERE ($1 = '' OR s.sequence_schema = $1)
+        ORDER BY s.sequence_schema, s.sequence_name
+    `, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sequences: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result.Rows)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) ListEnumTypes(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schema := req.GetString("schema", "")
+
+	rows, err := s.queryContext(ctx, `
+        SELECT n.nspname AS schema,
+               t.typname AS name,
+               array_agg(e.enumlabel ORDER BY e.enumsortorder) AS labels
+        FROM pg_catalog.pg_type t
+        JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+        JOIN pg_catalog.pg_enum e ON e.enumtypid = t.oid
+        WHERE ($1 = '' OR n.nspname = $1)
+        GROUP BY n.nspname, t.typname
+        ORDER BY n.nspname, t.typname
+    `, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list enum types: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result.Rows)
+	return mcp.NewToolResultText(string(response)), nil
+}
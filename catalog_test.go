@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTablesWithoutPK(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".with_pk (id int PRIMARY KEY)",
+		"CREATE TABLE "+schema+".unique_only (id int UNIQUE NOT NULL)",
+		"CREATE TABLE "+schema+".log_lines (line text)",
+		"CREATE VIEW "+schema+".log_view AS SELECT line FROM "+schema+".log_lines",
+	)
+
+	var got []string
+	callToolJSON(t, s.TablesWithoutPK, map[string]interface{}{"schema": schema}, &got)
+	if strings.Join(got, ",") != "log_lines,unique_only" {
+		t.Errorf("tables_without_pk = %v, want [log_lines unique_only]", got)
+	}
+
+	text, isError := callTool(t, s.TablesWithoutPK, map[string]interface{}{"schema": schema + "_missing"})
+	if !isError || !strings.Contains(text, "does not exist") {
+		t.Errorf("missing schema: got %q, want an error", text)
+	}
+}
+
+func TestForeignTables(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+
+	// A wrapper without a handler is enough to define foreign tables, but
+	// creating one needs superuser.
+	wrapper := fmt.Sprintf("pgmcp_fdw_%d", time.Now().UnixNano())
+	if _, err := s.db.Exec("CREATE FOREIGN DATA WRAPPER " + wrapper); err != nil {
+		t.Skipf("cannot create a foreign data wrapper: %v", err)
+	}
+	t.Cleanup(func() { s.db.Exec("DROP FOREIGN DATA WRAPPER " + wrapper + " CASCADE") })
+	mustExec(t, s,
+		"CREATE SERVER "+wrapper+"_srv FOREIGN DATA WRAPPER "+wrapper+" OPTIONS (host 'warehouse', port '5432')",
+		"CREATE FOREIGN TABLE "+schema+".remote_orders (id int) SERVER "+wrapper+"_srv",
+	)
+
+	var got []map[string]interface{}
+	callToolJSON(t, s.ForeignTables, nil, &got)
+
+	for _, row := range got {
+		if row["schema"] != schema {
+			continue
+		}
+		want := map[string]interface{}{
+			"schema":               schema,
+			"table_name":           "remote_orders",
+			"server":               wrapper + "_srv",
+			"foreign_data_wrapper": wrapper,
+			"server_options":       "host=warehouse, port=5432",
+		}
+		if fmt.Sprint(row) != fmt.Sprint(want) {
+			t.Errorf("foreign table = %v, want %v", row, want)
+		}
+		return
+	}
+	t.Errorf("foreign_tables = %v, want %s.remote_orders", got, schema)
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sqlStateTooManyConnections is reported when the server has no free
+// connection slots left.
+const sqlStateTooManyConnections = "53300"
+
+// isTooManyConnections reports whether err is the server refusing a new
+// connection because its connection limit has been reached.
+func isTooManyConnections(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == sqlStateTooManyConnections
+}
+
+// reconnectBackoff is the wait before the first reconnect attempt; it
+// doubles with every further attempt.
+const reconnectBackoff = 250 * time.Millisecond
+
+// isConnectionLost reports whether err means the connection to the server
+// broke or could not be made, e.g. because Postgres restarted or the
+// backend was terminated, as opposed to an error in the statement itself.
+func isConnectionLost(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 is connection exceptions; 57P01-57P03 are admin and crash
+		// shutdowns and a server that cannot accept connections yet.
+		return strings.HasPrefix(pgErr.Code, "08") ||
+			pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &connectErr) || errors.As(err, &netErr)
+}
+
+// withConnRetry calls fn and retries it when the database was unavailable:
+//
+//   - if the connection limit was reached and --conn-limit-retry-delay is
+//     set, it waits that long and calls fn once more;
+//   - if the connection was lost, it makes up to DB_RECONNECT_ATTEMPTS
+//     attempts, each waiting with exponential backoff and pinging the
+//     database before calling fn again, so that a restarted server does not
+//     fail every later tool call.
+func (s *PostgresServer) withConnRetry(ctx context.Context, fn func() error) error {
+	err := fn()
+	if err == nil {
+		return nil
+	}
+
+	if isTooManyConnections(err) {
+		if s.opts.ConnLimitRetryDelay <= 0 || !sleepContext(ctx, s.opts.ConnLimitRetryDelay) {
+			return err
+		}
+		return fn()
+	}
+
+	backoff := reconnectBackoff
+	for attempt := 0; attempt < s.opts.ReconnectAttempts && isConnectionLost(err); attempt++ {
+		if ctx.Err() != nil || !sleepContext(ctx, backoff) {
+			return err
+		}
+		backoff *= 2
+
+		if pingErr := s.db.PingContext(ctx); pingErr != nil {
+			err = pingErr
+			continue
+		}
+		err = fn()
+		if err == nil {
+			slog.Info("reconnected to database", "attempt", attempt+1)
+			return nil
+		}
+	}
+	return err
+}
+
+// sleepContext waits for d and reports whether it did so before ctx was
+// done.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// queryContext runs query on the pool, retrying on connection-limit and
+// lost-connection errors (see withConnRetry).
+func (s *PostgresServer) queryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := s.withConnRetry(ctx, func() error {
+		var err error
+		rows, err = s.db.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// errorText renders a database error for a tool result. Connection-limit
+// errors get an actionable message instead of the raw server text. With
+// --redact-errors, quoted values are masked so that literals echoed back by
+// the server do not leak into responses; the SQLSTATE is kept so callers can
+// still tell what went wrong.
+func (s *PostgresServer) errorText(err error) string {
+	if isTooManyConnections(err) {
+		return "database connection limit reached; retry shortly (SQLSTATE 53300)"
+	}
+	if !s.opts.RedactErrors {
+		return err.Error()
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return fmt.Sprintf("%s: %s (SQLSTATE %s)", pgErr.Severity, redactQuoted(pgErr.Message), pgErr.Code)
+	}
+	return redactQuoted(err.Error())
+}
+
+// SQLSTATE codes of references to tables and columns that do not exist
+const (
+	sqlStateUndefinedTable  = "42P01"
+	sqlStateUndefinedColumn = "42703"
+)
+
+// QueryError is the payload returned when a query fails. The fields after
+// Error are set when the server reported the failure, so that clients can
+// tell e.g. a syntax error (42601) from a missing table (42P01) or a
+// permission problem (42501) without parsing the text.
+type QueryError struct {
+	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+	// Position is the 1-based character position of the error in the
+	// query as the caller wrote it.
+	Position int32 `json:"position,omitempty"`
+	// Context shows the query line holding Position with a caret under
+	// the offending character, like psql.
+	Context string `json:"context,omitempty"`
+	// ExecutedQuery is the SQL that was run, set when the error points
+	// into what the server added to the caller's query (e.g. a LIMIT
+	// wrapper); Context then refers to it.
+	ExecutedQuery string `json:"executed_query,omitempty"`
+	// Schema lists the tables and columns when the query referenced one
+	// that does not exist.
+	Schema map[string][]map[string]string `json:"schema,omitempty"`
+}
+
+// queryError builds the QueryError for err, returned by executed, the SQL
+// run for the caller's original query. With --redact-errors the message and
+// hint are masked like errorText, and the detail, which usually echoes row
+// values, is left out.
+func (s *PostgresServer) queryError(err error, original, executed string) QueryError {
+	result := QueryError{Error: fmt.Sprintf("Query failed: %s", s.errorText(err))}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return result
+	}
+	result.Code = pgErr.Code
+	result.Message = pgErr.Message
+	result.Detail = pgErr.Detail
+	result.Hint = pgErr.Hint
+	if s.opts.RedactErrors {
+		result.Message = redactQuoted(result.Message)
+		result.Hint = redactQuoted(result.Hint)
+		result.Detail = ""
+	}
+
+	if pgErr.Position > 0 {
+		if position := originalPosition(original, executed, pgErr.Position); position > 0 {
+			result.Position = position
+			result.Context = errorContext(original, position)
+		} else {
+			result.ExecutedQuery = executed
+			result.Context = errorContext(executed, pgErr.Position)
+		}
+	}
+	if result.Context != "" {
+		result.Error += "\n" + result.Context
+	}
+	return result
+}
+
+// originalPosition maps position, a 1-based character position in
+// executed, to the same place in original. executed is either original or
+// embeds it with its comments stripped (see trimTerminator). It returns 0
+// when position points outside the embedded query, e.g. into an added LIMIT.
+func originalPosition(original, executed string, position int32) int32 {
+	if executed == original {
+		return position
+	}
+	// The wrappers embed the query in parentheses (see withDefaultLimit).
+	body := trimTerminator(original)
+	at := strings.Index(executed, "("+body+")")
+	if at >= 0 {
+		at++
+	}
+	offset := byteOffset(executed, position)
+	// A position just past the embedded query, e.g. at the closing
+	// parenthesis of the wrapper, means the query ended too early.
+	if at < 0 || offset < at || offset > at+len(body) {
+		return 0
+	}
+
+	// Every comment became a single space in the stripped query.
+	stripped := offset - at
+	removed := 0
+	for _, c := range sqlComments(original) {
+		start := c.start - removed
+		if stripped < start {
+			break
+		}
+		if stripped == start {
+			return charPosition(original, c.start)
+		}
+		removed += c.end - c.start - 1
+	}
+	return charPosition(original, stripped+removed)
+}
+
+// byteOffset returns the byte offset of the character at the 1-based
+// character position in s, len(s) for the position just past its end, and
+// -1 for positions outside s.
+func byteOffset(s string, position int32) int {
+	n := int32(1)
+	for i := range s {
+		if n == position {
+			return i
+		}
+		n++
+	}
+	if n == position {
+		return len(s)
+	}
+	return -1
+}
+
+// charPosition returns the 1-based character position of the byte at
+// offset in s.
+func charPosition(s string, offset int) int32 {
+	return int32(utf8.RuneCountInString(s[:offset])) + 1
+}
+
+// errorContext renders the line of query containing the 1-based character
+// position reported by the server, with a caret under that character:
+//
+//	LINE 2: FROM usres
+//	             ^
+//
+// It returns "" when position does not point into query.
+func errorContext(query string, position int32) string {
+	chars := []rune(query)
+	if position < 1 || int(position) > len(chars)+1 {
+		return ""
+	}
+	offset := int(position) - 1
+
+	start, line := 0, 1
+	for i := 0; i < offset; i++ {
+		if chars[i] == '\n' {
+			start, line = i+1, line+1
+		}
+	}
+	end := start
+	for end < len(chars) && chars[end] != '\n' {
+		end++
+	}
+
+	prefix := fmt.Sprintf("LINE %d: ", line)
+	lineText := strings.TrimRight(string(chars[start:end]), "\r")
+
+	// Keep tabs in the padding so the caret lines up however they render.
+	pad := []rune(strings.Repeat(" ", len(prefix)))
+	for _, c := range chars[start:offset] {
+		if c == '\t' {
+			pad = append(pad, '\t')
+		} else {
+			pad = append(pad, ' ')
+		}
+	}
+	return prefix + lineText + "\n" + string(pad) + "^"
+}
+
+// queryFailed returns err, returned by executed, as a structured tool error
+// (see queryError).
+func (s *PostgresServer) queryFailed(err error, original, executed string) *mcp.CallToolResult {
+	return queryErrorResult(s.queryError(err, original, executed))
+}
+
+func queryErrorResult(queryErr QueryError) *mcp.CallToolResult {
+	response, _ := json.Marshal(queryErr)
+	return mcp.NewToolResultError(string(response))
+}
+
+// redactQuoted replaces the contents of every single- or double-quoted
+// section of msg with ***.
+func redactQuoted(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); {
+		c := msg[i]
+		if c != '\'' && c != '"' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(msg[i+1:], c)
+		if end < 0 {
+			b.WriteString(msg[i:])
+			break
+		}
+		b.WriteByte(c)
+		b.WriteString("***")
+		b.WriteByte(c)
+		i += end + 2
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRedactQuoted(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{`invalid input syntax for type integer: "hunter2"`, `invalid input syntax for type integer: "***"`},
+		{`value 'secret' and 'other'`, `value '***' and '***'`},
+		{`no quotes here`, `no quotes here`},
+		{`unterminated 'quote`, `unterminated 'quote`},
+	}
+	for _, tt := range tests {
+		if got := redactQuoted(tt.msg); got != tt.want {
+			t.Errorf("redactQuoted(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestErrorTextRedactsQuotedLiteral(t *testing.T) {
+	err := &pgconn.PgError{
+		Severity: "ERROR",
+		Code:     "22P02",
+		Message:  `invalid input syntax for type integer: "hunter2"`,
+	}
+
+	redacted := (&PostgresServer{opts: ServerOptions{RedactErrors: true}}).errorText(err)
+	if strings.Contains(redacted, "hunter2") {
+		t.Errorf("errorText() = %q, leaks the literal", redacted)
+	}
+	if !strings.Contains(redacted, "SQLSTATE 22P02") || !strings.Contains(redacted, "invalid input syntax for type integer") {
+		t.Errorf("errorText() = %q, want the message structure and SQLSTATE kept", redacted)
+	}
+
+	if plain := (&PostgresServer{}).errorText(err); !strings.Contains(plain, "hunter2") {
+		t.Errorf("errorText() without redaction = %q, want the literal", plain)
+	}
+
+	queryErr := (&PostgresServer{opts: ServerOptions{RedactErrors: true}}).queryError(err, "SELECT 'hunter2'::int", "SELECT 'hunter2'::int")
+	if strings.Contains(queryErr.Message, "hunter2") || queryErr.Code != "22P02" {
+		t.Errorf("queryError() = %+v, want a masked message and code 22P02", queryErr)
+	}
+}
+
+func TestErrorTextNonServerError(t *testing.T) {
+	s := &PostgresServer{opts: ServerOptions{RedactErrors: true}}
+	if got := s.errorText(errors.New(`dial 'db.internal' failed`)); got != `dial '***' failed` {
+		t.Errorf("errorText() = %q", got)
+	}
+}
+
+func TestWithConnRetryTooManyConnections(t *testing.T) {
+	tooMany := &pgconn.PgError{Severity: "FATAL", Code: sqlStateTooManyConnections, Message: "sorry, too many clients already"}
+
+	s := &PostgresServer{opts: ServerOptions{ConnLimitRetryDelay: time.Millisecond}}
+	calls := 0
+	err := s.withConnRetry(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return tooMany
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("withConnRetry() = %v after %d calls, want success on the retry", err, calls)
+	}
+
+	calls = 0
+	err = s.withConnRetry(context.Background(), func() error {
+		calls++
+		return tooMany
+	})
+	if calls != 2 {
+		t.Errorf("fn called %d times, want exactly one retry", calls)
+	}
+	if got, want := s.errorText(err), "database connection limit reached; retry shortly (SQLSTATE 53300)"; got != want {
+		t.Errorf("errorText() = %q, want %q", got, want)
+	}
+
+	// Without a retry delay the error is returned straight away.
+	calls = 0
+	s = &PostgresServer{}
+	if err := s.withConnRetry(context.Background(), func() error {
+		calls++
+		return tooMany
+	}); !isTooManyConnections(err) || calls != 1 {
+		t.Errorf("withConnRetry() = %v after %d calls, want the error without a retry", err, calls)
+	}
+}
+
+func TestOriginalPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		executed string
+		// at is the text in executed the server's position points to, and
+		// want the text the mapped position should point to in original.
+		at   string
+		want string
+	}{
+		{"unchanged", "SELECT nope FROM t", "SELECT nope FROM t", "nope", "nope"},
+		{"wrapped", "SELECT nope FROM t", withDefaultLimit("SELECT nope FROM t", 10), "nope", "nope"},
+		{"after comment", "-- list them\nSELECT /* all */ nope FROM t;", withDefaultLimit("-- list them\nSELECT /* all */ nope FROM t;", 10), "nope", "nope"},
+		{"multibyte", "SELECT 'ünïcödé' /* ✓ */, nope", withPage("SELECT 'ünïcödé' /* ✓ */, nope", 5, 5), "nope", "nope"},
+		{"multi-line", "SELECT 1,\n  -- why\n  nope\nFROM t", withDefaultLimit("SELECT 1,\n  -- why\n  nope\nFROM t", 10), "nope", "nope"},
+	}
+	for _, tt := range tests {
+		position := int32(utf8.RuneCountInString(tt.executed[:strings.Index(tt.executed, tt.at)])) + 1
+		got := originalPosition(tt.original, tt.executed, position)
+		want := int32(utf8.RuneCountInString(tt.original[:strings.Index(tt.original, tt.want)])) + 1
+		if got != want {
+			t.Errorf("%s: originalPosition() = %d, want %d", tt.name, got, want)
+		}
+	}
+
+	// A position in the wrapper has no counterpart in the original.
+	executed := withDefaultLimit("SELECT 1", 10)
+	position := int32(strings.Index(executed, "LIMIT")) + 1
+	if got := originalPosition("SELECT 1", executed, position); got != 0 {
+		t.Errorf("originalPosition() in the LIMIT = %d, want 0", got)
+	}
+}
+
+func TestQueryErrorContextUsesOriginalQuery(t *testing.T) {
+	original := "/* report */ SELECT nope\nFROM t"
+	executed := withDefaultLimit(original, 10)
+	err := &pgconn.PgError{
+		Code:     "42703",
+		Message:  `column "nope" does not exist`,
+		Position: int32(strings.Index(executed, "nope")) + 1,
+	}
+
+	got := (&PostgresServer{}).queryError(err, original, executed)
+	if want := "LINE 1: /* report */ SELECT nope\n" + strings.Repeat(" ", 28) + "^"; got.Context != want {
+		t.Errorf("Context = %q, want %q", got.Context, want)
+	}
+	if got.Position != int32(strings.Index(original, "nope"))+1 || got.ExecutedQuery != "" {
+		t.Errorf("queryError() = %+v, want the position in the original query", got)
+	}
+
+	// An error in the wrapper is shown against the SQL that ran.
+	err.Position = int32(strings.Index(executed, "LIMIT")) + 1
+	got = (&PostgresServer{}).queryError(err, original, executed)
+	if got.ExecutedQuery != executed || got.Position != 0 || !strings.Contains(got.Context, "LIMIT") {
+		t.Errorf("queryError() = %+v, want the context in the executed query", got)
+	}
+}
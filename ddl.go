@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func (s *PostgresServer) GetTableDDL(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	schema, table, err := resolveTableName(req.GetString("schema", defaultSchema), table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	ddl, err := s.tableDDL(ctx, schema, table)
+	if errors.Is(err, sql.ErrNoRows) {
+		return mcp.NewToolResultError(fmt.Sprintf("Table %s.%s does not exist", schema, table)), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to build table DDL: %w", err)
+	}
+	return mcp.NewToolResultText(ddl), nil
+}
+
+// tableDDL reconstructs the CREATE TABLE statement of schema.table from the
+// catalog: columns with their types, defaults, identity/generated clauses
+// and nullability, followed by the primary key, unique, foreign key and
+// check constraints. Indexes, triggers and ownership are not included. It
+// returns sql.ErrNoRows when there is no such table.
+func (s *PostgresServer) tableDDL(ctx context.Context, schema, table string) (string, error) {
+	var oid int64
+	var name, partitionKey string
+	err := s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT c.oid, format('%I.%I', n.nspname, c.relname),
+                   CASE WHEN c.relkind = 'p' THEN pg_catalog.pg_get_partkeydef(c.oid) ELSE '' END
+            FROM pg_catalog.pg_class c
+            JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+            WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN ('r', 'p')
+        `, schema, table).Scan(&oid, &name, &partitionKey)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	columns, err := s.queryContext(ctx, `
+        SELECT quote_ident(a.attname),
+               pg_catalog.format_type(a.atttypid, a.atttypmod),
+               a.attnotnull,
+               a.attidentity,
+               a.attgenerated,
+               coalesce(pg_catalog.pg_get_expr(d.adbin, d.adrelid), '')
+        FROM pg_catalog.pg_attribute a
+        LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+        WHERE a.attrelid = $1::oid AND a.attnum > 0 AND NOT a.attisdropped
+        ORDER BY a.attnum
+    `, oid)
+	if err != nil {
+		return "", err
+	}
+	defer columns.Close()
+
+	var lines []string
+	for columns.Next() {
+		var column, dataType, identity, generated, expr string
+		var notNull bool
+		if err := columns.Scan(&column, &dataType, &notNull, &identity, &generated, &expr); err != nil {
+			return "", err
+		}
+
+		line := column + " " + dataType
+		switch {
+		case generated == "s":
+			line += " GENERATED ALWAYS AS (" + expr + ") STORED"
+		case identity == "a":
+			line += " GENERATED ALWAYS AS IDENTITY"
+		case identity == "d":
+			line += " GENERATED BY DEFAULT AS IDENTITY"
+		case expr != "":
+			line += " DEFAULT " + expr
+		}
+		if notNull {
+			line += " NOT NULL"
+		}
+		lines = append(lines, line)
+	}
+	if err := columns.Err(); err != nil {
+		return "", err
+	}
+
+	constraints, err := s.queryContext(ctx, `
+        SELECT quote_ident(conname), pg_catalog.pg_get_constraintdef(oid, true)
+        FROM pg_catalog.pg_constraint
+        WHERE conrelid = $1::oid AND contype IN ('p', 'u', 'f', 'c')
+        ORDER BY array_position(ARRAY['p', 'u', 'f', 'c'], contype::text), conname
+    `, oid)
+	if err != nil {
+		return "", err
+	}
+	defer constraints.Close()
+
+	for constraints.Next() {
+		var constraint, definition string
+		if err := constraints.Scan(&constraint, &definition); err != nil {
+			return "", err
+		}
+		lines = append(lines, "CONSTRAINT "+constraint+" "+definition)
+	}
+	if err := constraints.Err(); err != nil {
+		return "", err
+	}
+
+	ddl := "CREATE TABLE " + name + " (\n    " + strings.Join(lines, ",\n    ") + "\n)"
+	if partitionKey != "" {
+		ddl += " PARTITION BY " + partitionKey
+	}
+	return ddl + ";\n", nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Row caps accepted by diff_results
+const (
+	defaultDiffRows = 1000
+	maxDiffRows     = 10000
+)
+
+// DiffResult reports the rows returned by only one of two queries
+type DiffResult struct {
+	Columns   []string                 `json:"columns"`
+	OnlyInA   []map[string]interface{} `json:"only_in_a"`
+	OnlyInB   []map[string]interface{} `json:"only_in_b"`
+	RowsA     int                      `json:"rows_a"`
+	RowsB     int                      `json:"rows_b"`
+	Truncated bool                     `json:"truncated"`
+}
+
+func (s *PostgresServer) DiffResults(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	queryA, err := req.RequireString("query_a")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query_a'"), nil
+	}
+	queryB, err := req.RequireString("query_b")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query_b'"), nil
+	}
+
+	maxRows := req.GetInt("max_rows", defaultDiffRows)
+	if maxRows < 1 || maxRows > maxDiffRows {
+		return mcp.NewToolResultError(fmt.Sprintf("max_rows must be between 1 and %d", maxDiffRows)), nil
+	}
+
+	if err := s.isSafeQuery(queryA); err != nil {
+		return nil, fmt.Errorf("unsafe query_a: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, queryA); err != nil {
+		return nil, err
+	}
+	if err := s.isSafeQuery(queryB); err != nil {
+		return nil, fmt.Errorf("unsafe query_b: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, queryB); err != nil {
+		return nil, err
+	}
+
+	resultA, moreA, err := s.queryLimited(ctx, queryA, maxRows)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("query_a failed: %s", s.errorText(err))), nil
+	}
+	resultB, moreB, err := s.queryLimited(ctx, queryB, maxRows)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("query_b failed: %s", s.errorText(err))), nil
+	}
+
+	if !sameColumns(resultA.Columns, resultB.Columns) {
+		return mcp.NewToolResultError(fmt.Sprintf("queries return different columns: (%s) vs (%s)",
+			strings.Join(resultA.Columns, ", "), strings.Join(resultB.Columns, ", "))), nil
+	}
+
+	onlyInA, onlyInB := diffRows(resultA.Rows, resultB.Rows)
+	response, _ := json.Marshal(DiffResult{
+		Columns:   resultA.Columns,
+		OnlyInA:   onlyInA,
+		OnlyInB:   onlyInB,
+		RowsA:     resultA.Count,
+		RowsB:     resultB.Count,
+		Truncated: moreA || moreB,
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// queryLimited runs query in a read-only transaction, wrapped to stop after
+// maxRows rows plus one, and reads at most maxRows rows of its result.
+// Masked columns are masked before the rows are compared, so rows differing
+// only in a masked value count as equal.
+func (s *PostgresServer) queryLimited(ctx context.Context, query string, maxRows int) (*QueryResult, bool, error) {
+	tx, err := s.beginReadOnlyTx(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	executed := query
+	if maxRows > 0 {
+		executed = withDefaultLimit(query, maxRows)
+	}
+	rows, err := tx.QueryContext(ctx, executed)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	return s.scanQueryRows(ctx, rows, maxRows, query)
+}
+
+// sameColumns reports whether a and b hold the same column names, in any order.
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffRows returns the rows of a missing from b and the rows of b missing
+// from a, comparing rows by all of their columns. Duplicates count: a row
+// appearing twice in a and once in b is reported once in the first result.
+func diffRows(a, b []map[string]interface{}) (onlyInA, onlyInB []map[string]interface{}) {
+	counts := make(map[string]int)
+	for _, row := range b {
+		counts[rowKey(row)]++
+	}
+
+	onlyInA = make([]map[string]interface{}, 0)
+	for _, row := range a {
+		key := rowKey(row)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		onlyInA = append(onlyInA, row)
+	}
+
+	onlyInB = make([]map[string]interface{}, 0)
+	for _, row := range b {
+		key := rowKey(row)
+		if counts[key] > 0 {
+			counts[key]--
+			onlyInB = append(onlyInB, row)
+		}
+	}
+	return onlyInA, onlyInB
+}
+
+// rowKey identifies a row by all of its column values. encoding/json sorts
+// map keys, so equal rows always produce the same key.
+func rowKey(row map[string]interface{}) string {
+	key, _ := json.Marshal(row)
+	return string(key)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiffRows(t *testing.T) {
+	row := func(id int, name string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "name": name}
+	}
+	a := []map[string]interface{}{row(1, "a"), row(2, "b"), row(2, "b"), row(3, "c")}
+	b := []map[string]interface{}{row(3, "c"), row(2, "b"), row(4, "d"), row(4, "d")}
+
+	onlyInA, onlyInB := diffRows(a, b)
+	if fmt.Sprint(onlyInA) != fmt.Sprint([]map[string]interface{}{row(1, "a"), row(2, "b")}) {
+		t.Errorf("only in a = %v, want 1/a and one 2/b", onlyInA)
+	}
+	if fmt.Sprint(onlyInB) != fmt.Sprint([]map[string]interface{}{row(4, "d"), row(4, "d")}) {
+		t.Errorf("only in b = %v, want 4/d twice", onlyInB)
+	}
+}
+
+func TestSameColumns(t *testing.T) {
+	if !sameColumns([]string{"a", "b"}, []string{"b", "a"}) {
+		t.Error("column order should not matter")
+	}
+	if sameColumns([]string{"a", "b"}, []string{"a", "c"}) || sameColumns([]string{"a"}, []string{"a", "b"}) {
+		t.Error("different columns compared equal")
+	}
+}
+
+func TestDiffResultsOneRow(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	var got DiffResult
+	callToolJSON(t, s.DiffResults, map[string]interface{}{
+		"query_a": "SELECT g AS id, 'row ' || g AS label FROM generate_series(1, 10) g",
+		"query_b": "SELECT g AS id, 'row ' || g AS label FROM generate_series(1, 10) g WHERE g <> 7",
+	}, &got)
+
+	if got.RowsA != 10 || got.RowsB != 9 || got.Truncated {
+		t.Errorf("rows = %d and %d (truncated: %v), want 10 and 9", got.RowsA, got.RowsB, got.Truncated)
+	}
+	if len(got.OnlyInA) != 1 || got.OnlyInA[0]["id"] != float64(7) || got.OnlyInA[0]["label"] != "row 7" {
+		t.Errorf("only_in_a = %v, want row 7", got.OnlyInA)
+	}
+	if len(got.OnlyInB) != 0 {
+		t.Errorf("only_in_b = %v, want none", got.OnlyInB)
+	}
+}
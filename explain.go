@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// planEstimate holds the planner's estimates for the top node of a query plan.
+type planEstimate struct {
+	NodeType    string  `json:"node_type"`
+	StartupCost float64 `json:"startup_cost"`
+	TotalCost   float64 `json:"total_cost"`
+	PlanRows    float64 `json:"plan_rows"`
+	PlanWidth   int     `json:"plan_width"`
+}
+
+// explainEstimate runs EXPLAIN (without ANALYZE, so the query is planned but
+// never executed) and returns the estimates of the top plan node.
+func (s *PostgresServer) explainEstimate(ctx context.Context, query string) (*planEstimate, error) {
+	var raw []byte
+	err := s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query).Scan(&raw)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []struct {
+		Plan struct {
+			NodeType    string  `json:"Node Type"`
+			StartupCost float64 `json:"Startup Cost"`
+			TotalCost   float64 `json:"Total Cost"`
+			PlanRows    float64 `json:"Plan Rows"`
+			PlanWidth   int     `json:"Plan Width"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("EXPLAIN returned no plan")
+	}
+
+	p := plans[0].Plan
+	return &planEstimate{
+		NodeType:    p.NodeType,
+		StartupCost: p.StartupCost,
+		TotalCost:   p.TotalCost,
+		PlanRows:    p.PlanRows,
+		PlanWidth:   p.PlanWidth,
+	}, nil
+}
+
+// checkEstimatedRows rejects query when the planner expects it to return more
+// than MaxEstimatedRows rows. Queries that cannot be planned are let through
+// so that running them reports the real error.
+func (s *PostgresServer) checkEstimatedRows(ctx context.Context, query string) error {
+	if s.opts.MaxEstimatedRows <= 0 {
+		return nil
+	}
+
+	plan, err := s.explainEstimate(ctx, query)
+	if err != nil {
+		return nil
+	}
+	if plan.PlanRows > float64(s.opts.MaxEstimatedRows) {
+		return fmt.Errorf("query rejected: the planner estimates %.0f result rows, above the limit of %d; "+
+			"if the query joins tables, make sure every join has a join condition (ON, USING or WHERE) to avoid a cartesian product",
+			plan.PlanRows, s.opts.MaxEstimatedRows)
+	}
+	return nil
+}
+
+func (s *PostgresServer) ComparePlans(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	queryA, err := req.RequireString("query_a")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query_a'"), nil
+	}
+	queryB, err := req.RequireString("query_b")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query_b'"), nil
+	}
+
+	if err := s.isSafeQuery(queryA); err != nil {
+		return nil, fmt.Errorf("unsafe query_a: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, queryA); err != nil {
+		return nil, err
+	}
+	if err := s.isSafeQuery(queryB); err != nil {
+		return nil, fmt.Errorf("unsafe query_b: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, queryB); err != nil {
+		return nil, err
+	}
+
+	planA, err := s.explainEstimate(ctx, queryA)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to explain query_a: %s", s.errorText(err))), nil
+	}
+	planB, err := s.explainEstimate(ctx, queryB)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to explain query_b: %s", s.errorText(err))), nil
+	}
+
+	var cheaper, recommendation string
+	switch {
+	case planA.TotalCost < planB.TotalCost:
+		cheaper = "query_a"
+		recommendation = costRecommendation("query_a", planA.TotalCost, planB.TotalCost)
+	case planB.TotalCost < planA.TotalCost:
+		cheaper = "query_b"
+		recommendation = costRecommendation("query_b", planB.TotalCost, planA.TotalCost)
+	default:
+		cheaper = "equal"
+		recommendation = fmt.Sprintf("Both queries have the same estimated total cost (%.2f)", planA.TotalCost)
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"query_a":        planA,
+		"query_b":        planB,
+		"cheaper":        cheaper,
+		"recommendation": recommendation,
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func costRecommendation(name string, cheap, expensive float64) string {
+	if cheap <= 0 {
+		return fmt.Sprintf("Prefer %s: estimated total cost %.2f vs %.2f", name, cheap, expensive)
+	}
+	return fmt.Sprintf("Prefer %s: estimated total cost %.2f vs %.2f (%.1fx cheaper)", name, cheap, expensive, expensive/cheap)
+}
+
+func (s *PostgresServer) ResultSizeEstimate(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query'"), nil
+	}
+
+	if err := s.isSafeQuery(query); err != nil {
+		return nil, fmt.Errorf("unsafe query: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, query); err != nil {
+		return nil, err
+	}
+
+	plan, err := s.explainEstimate(ctx, query)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to explain query: %s", s.errorText(err))), nil
+	}
+
+	// Plan Width is the planner's average output row width in bytes, derived
+	// from pg_stats.avg_width for the columns involved.
+	estimatedBytes := int64(plan.PlanRows * float64(plan.PlanWidth))
+	response, _ := json.Marshal(map[string]interface{}{
+		"estimated_rows":    int64(plan.PlanRows),
+		"average_row_bytes": plan.PlanWidth,
+		"estimated_bytes":   estimatedBytes,
+		"estimated_size":    formatBytes(estimatedBytes),
+		"note":              "Planner estimate based on table statistics; refresh with ANALYZE if it looks off. Actual JSON output is larger than the raw row size.",
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// formatBytes renders n as a human-readable size using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+func (s *PostgresServer) ExplainQuery(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query'"), nil
+	}
+
+	if err := s.isSafeQuery(query); err != nil {
+		return nil, fmt.Errorf("unsafe query: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, query); err != nil {
+		return nil, err
+	}
+
+	// Plain EXPLAIN plans the query without running it; each result row is
+	// one line of the text plan.
+	rows, err := s.queryContext(ctx, "EXPLAIN "+query)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to explain query: %s", s.errorText(err))), nil
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to explain query: %s", s.errorText(err))), nil
+	}
+
+	return mcp.NewToolResultText(strings.Join(lines, "\n")), nil
+}
+
+// AnalyzeResult is the outcome of EXPLAIN (ANALYZE, BUFFERS) for a query
+type AnalyzeResult struct {
+	PlanningTimeMS   float64 `json:"planning_time_ms"`
+	ExecutionTimeMS  float64 `json:"execution_time_ms"`
+	SharedHitBlocks  int64   `json:"shared_hit_blocks"`
+	SharedReadBlocks int64   `json:"shared_read_blocks"`
+	// Plan is the full plan tree as returned by Postgres, with per-node
+	// timings and buffer counts.
+	Plan json.RawMessage `json:"plan"`
+}
+
+func (s *PostgresServer) ExplainAnalyze(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if !s.opts.AllowAnalyze {
+		return mcp.NewToolResultError("explain_analyze is disabled: it executes the query, so it must be enabled with DB_ALLOW_ANALYZE=true. Use explain_query for the plan without running the query."), nil
+	}
+
+	query, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query'"), nil
+	}
+
+	if err := s.isSafeQuery(query); err != nil {
+		return nil, fmt.Errorf("unsafe query: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, query); err != nil {
+		return nil, err
+	}
+
+	// ANALYZE really runs the query, so keep it in a read-only transaction
+	// that is rolled back afterwards.
+	tx, err := s.beginReadOnlyTx(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	defer tx.Rollback()
+
+	var raw []byte
+	if err := tx.QueryRowContext(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+query).Scan(&raw); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to explain query: %s", s.errorText(err))), nil
+	}
+
+	var plans []struct {
+		Plan struct {
+			SharedHitBlocks  int64 `json:"Shared Hit Blocks"`
+			SharedReadBlocks int64 `json:"Shared Read Blocks"`
+		} `json:"Plan"`
+		PlanningTime  float64 `json:"Planning Time"`
+		ExecutionTime float64 `json:"Execution Time"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("EXPLAIN returned no plan")
+	}
+
+	// Buffer counts of the top node include those of its children.
+	p := plans[0]
+	response, _ := json.Marshal(AnalyzeResult{
+		PlanningTimeMS:   p.PlanningTime,
+		ExecutionTimeMS:  p.ExecutionTime,
+		SharedHitBlocks:  p.Plan.SharedHitBlocks,
+		SharedReadBlocks: p.Plan.SharedReadBlocks,
+		Plan:             raw,
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
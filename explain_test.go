@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestComparePlansPrefersIndexedQuery(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".events (id int PRIMARY KEY, payload text)",
+		"INSERT INTO "+schema+".events SELECT g, md5(g::text) FROM generate_series(1, 10000) g",
+		"ANALYZE "+schema+".events",
+	)
+
+	var got struct {
+		QueryA         planEstimate `json:"query_a"`
+		QueryB         planEstimate `json:"query_b"`
+		Cheaper        string       `json:"cheaper"`
+		Recommendation string       `json:"recommendation"`
+	}
+	callToolJSON(t, s.ComparePlans, map[string]interface{}{
+		"query_a": "SELECT * FROM " + schema + ".events WHERE payload = 'x'",
+		"query_b": "SELECT * FROM " + schema + ".events WHERE id = 42",
+	}, &got)
+
+	if got.QueryA.NodeType != "Seq Scan" {
+		t.Errorf("query_a node = %q, want Seq Scan", got.QueryA.NodeType)
+	}
+	if !strings.Contains(got.QueryB.NodeType, "Index") {
+		t.Errorf("query_b node = %q, want an index scan", got.QueryB.NodeType)
+	}
+	if got.Cheaper != "query_b" || !strings.HasPrefix(got.Recommendation, "Prefer query_b") {
+		t.Errorf("cheaper = %q, recommendation = %q, want query_b", got.Cheaper, got.Recommendation)
+	}
+}
+
+func TestComparePlansRejectsUnsafeQuery(t *testing.T) {
+	s := &PostgresServer{}
+	text, isError := callTool(t, s.ComparePlans, map[string]interface{}{
+		"query_a": "SELECT 1",
+		"query_b": "DELETE FROM t",
+	})
+	if !isError || !strings.Contains(text, "unsafe query_b") {
+		t.Errorf("got %q (error: %v), want query_b rejected", text, isError)
+	}
+}
+
+func TestCostRecommendation(t *testing.T) {
+	if got, want := costRecommendation("query_a", 10, 25), "Prefer query_a: estimated total cost 10.00 vs 25.00 (2.5x cheaper)"; got != want {
+		t.Errorf("costRecommendation() = %q, want %q", got, want)
+	}
+	if got, want := costRecommendation("query_b", 0, 5), "Prefer query_b: estimated total cost 0.00 vs 5.00"; got != want {
+		t.Errorf("costRecommendation() = %q, want %q", got, want)
+	}
+}
+
+func TestMaxEstimatedRowsRejectsCrossJoin(t *testing.T) {
+	s := newTestServer(t, ServerOptions{MaxEstimatedRows: 10000})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".a (id int PRIMARY KEY)",
+		"CREATE TABLE "+schema+".b (id int PRIMARY KEY, a_id int)",
+		"INSERT INTO "+schema+".a SELECT generate_series(1, 1000)",
+		"INSERT INTO "+schema+".b SELECT g, g FROM generate_series(1, 1000) g",
+		"ANALYZE "+schema+".a",
+		"ANALYZE "+schema+".b",
+	)
+
+	text, isError := callTool(t, s.ExecuteQuery, map[string]interface{}{
+		"query": "SELECT * FROM " + schema + ".a, " + schema + ".b",
+	})
+	if !isError || !strings.Contains(text, "cartesian product") || !strings.Contains(text, "above the limit of 10000") {
+		t.Errorf("cross join: got %q (error: %v), want it rejected with a join hint", text, isError)
+	}
+
+	text, isError = callTool(t, s.ExecuteQuery, map[string]interface{}{
+		"query": "SELECT * FROM " + schema + ".a JOIN " + schema + ".b ON b.a_id = a.id",
+	})
+	if isError {
+		t.Errorf("join with a condition was rejected: %s", text)
+	}
+}
+
+func TestResultSizeEstimate(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".items (id bigint PRIMARY KEY, code char(32))",
+		"INSERT INTO "+schema+".items SELECT g, md5(g::text) FROM generate_series(1, 5000) g",
+		"ANALYZE "+schema+".items",
+	)
+
+	var got struct {
+		EstimatedRows   int64 `json:"estimated_rows"`
+		AverageRowBytes int   `json:"average_row_bytes"`
+		EstimatedBytes  int64 `json:"estimated_bytes"`
+	}
+	callToolJSON(t, s.ResultSizeEstimate, map[string]interface{}{
+		"query": "SELECT id, code FROM " + schema + ".items",
+	}, &got)
+
+	if got.EstimatedRows != 5000 {
+		t.Errorf("estimated_rows = %d, want 5000", got.EstimatedRows)
+	}
+	// An 8-byte id and a 32-character code, plus a header byte for the code.
+	if got.AverageRowBytes < 40 || got.AverageRowBytes > 48 {
+		t.Errorf("average_row_bytes = %d, want about 41", got.AverageRowBytes)
+	}
+	if got.EstimatedBytes != got.EstimatedRows*int64(got.AverageRowBytes) {
+		t.Errorf("estimated_bytes = %d, want rows x width", got.EstimatedBytes)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:           "0 bytes",
+		1023:        "1023 bytes",
+		1024:        "1.0 kB",
+		1536:        "1.5 kB",
+		5 << 20:     "5.0 MB",
+		3 << 30 / 2: "1.5 GB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestExplainAnalyzeDisabled(t *testing.T) {
+	s := &PostgresServer{opts: ServerOptions{AllowAnalyze: false}}
+	srv := server.NewMCPServer("test", "0")
+	s.setupMCPTools(srv)
+
+	// The tool is listed even when disabled, and says how to enable it.
+	response, _ := json.Marshal(srv.HandleMessage(context.Background(), []byte(
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"explain_analyze","arguments":{"query":"SELECT 1"}}}`)))
+	if !strings.Contains(string(response), `"isError":true`) || !strings.Contains(string(response), "DB_ALLOW_ANALYZE=true") {
+		t.Errorf("got %s, want an error naming DB_ALLOW_ANALYZE", response)
+	}
+}
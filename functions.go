@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func (s *PostgresServer) ListFunctions(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schema := req.GetString("schema", defaultSchema)
+	if result, err := s.requireSchema(ctx, schema); result != nil || err != nil {
+		return result, err
+	}
+
+	rows, err := s.queryContext(ctx, `
+        SELECT p.proname AS name,
+               pg_catalog.pg_get_function_arguments(p.oid) AS arguments,
+               pg_catalog.pg_get_function_result(p.oid) AS return_type,
+               l.lanname AS language,
+               CASE p.prokind
+                   WHEN 'p' THEN 'procedure'
+                   WHEN 'a' THEN 'aggregate'
+                   WHEN 'w' THEN 'window'
+                   ELSE 'function'
+               END AS kind
+        FROM pg_catalog.pg_proc p
+        JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+        JOIN pg_catalog.pg_language l ON l.oid = p.prolang
+        WHERE n.nspname = $1
+        ORDER BY p.proname, pg_catalog.pg_get_function_identity_arguments(p.oid)
+    `, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list functions: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result.Rows)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) GetFunctionSource(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := req.RequireString("name")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'name'"), nil
+	}
+	schema := req.GetString("schema", defaultSchema)
+	arguments, hasArguments := req.GetArguments()["arguments"].(string)
+
+	var oid sql.NullInt64
+	if hasArguments {
+		// Resolve the signature the way Postgres does, so that e.g. "int"
+		// matches a function declared with "integer".
+		err = s.withConnRetry(ctx, func() error {
+			return s.db.QueryRowContext(ctx,
+				"SELECT to_regprocedure(format('%I.%I(%s)', $1::text, $2::text, $3::text))::oid",
+				schema, name, arguments).Scan(&oid)
+		})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments %q: %s", arguments, s.errorText(err))), nil
+		}
+		if !oid.Valid {
+			return mcp.NewToolResultError(fmt.Sprintf("Function %s.%s(%s) does not exist", schema, name, arguments)), nil
+		}
+	} else {
+		candidates, err := s.functionCandidates(ctx, schema, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up function: %w", err)
+		}
+		switch len(candidates) {
+		case 0:
+			return mcp.NewToolResultError(fmt.Sprintf("Function %s.%s does not exist", schema, name)), nil
+		case 1:
+			oid = sql.NullInt64{Int64: candidates[0].oid, Valid: true}
+		default:
+			signatures := make([]string, len(candidates))
+			for i, c := range candidates {
+				signatures[i] = fmt.Sprintf("%s(%s)", name, c.arguments)
+			}
+			return mcp.NewToolResultError(fmt.Sprintf(
+				"Function %s.%s is overloaded; pass 'arguments' with the argument types of one of: %s",
+				schema, name, strings.Join(signatures, ", "))), nil
+		}
+	}
+
+	var source string
+	err = s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, "SELECT pg_catalog.pg_get_functiondef($1::oid)", oid.Int64).Scan(&source)
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get function source: %s", s.errorText(err))), nil
+	}
+	return mcp.NewToolResultText(source), nil
+}
+
+// functionCandidate is one overload of a function name
+type functionCandidate struct {
+	oid       int64
+	arguments string
+}
+
+// functionCandidates returns the functions and procedures named name in
+// schema, one per overload. Aggregates are left out since they have no
+// source to show.
+func (s *PostgresServer) functionCandidates(ctx context.Context, schema, name string) ([]functionCandidate, error) {
+	rows, err := s.queryContext(ctx, `
+        SELECT p.oid, pg_catalog.pg_get_function_identity_arguments(p.oid)
+        FROM pg_catalog.pg_proc p
+        JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+        WHERE n.nspname = $1 AND p.proname = $2 AND p.prokind <> 'a'
+        ORDER BY 2
+    `, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []functionCandidate
+	for rows.Next() {
+		var c functionCandidate
+		if err := rows.Scan(&c.oid, &c.arguments); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultSchema is the schema the introspection tools look at unless told
+// otherwise.
+const defaultSchema = "public"
+
+// readinessTimeout bounds the checks behind /readyz.
+const readinessTimeout = 5 * time.Second
+
+// healthTimeout bounds the ping behind /healthz.
+const healthTimeout = 2 * time.Second
+
+// checkReady confirms the database is reachable and initialized enough to
+// serve the tools: schema must exist and listing its tables must work.
+func (s *PostgresServer) checkReady(ctx context.Context, schema string) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+
+	exists, err := s.schemaExists(ctx, schema)
+	if err != nil {
+		return fmt.Errorf("failed to check schema %q: %w", schema, err)
+	}
+	if !exists {
+		return fmt.Errorf("schema %q does not exist", schema)
+	}
+
+	rows, err := s.db.QueryContext(ctx, listTablesQueries[s.opts.IntrospectionSource], schema)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	return nil
+}
+
+// ReadyHandler serves /readyz: 200 once the database can serve the tools,
+// 503 with the reason otherwise.
+func (s *PostgresServer) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.checkReady(ctx, defaultSchema); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "not ready", "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
+
+// HealthHandler serves /healthz: 200 when the database answers a ping, 503
+// with the error otherwise.
+func (s *PostgresServer) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.db.PingContext(ctx); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
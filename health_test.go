@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadyHandler(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	rec := httptest.NewRecorder()
+	s.ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body map[string]string
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusOK || body["status"] != "ready" {
+		t.Errorf("/readyz = %d %v, want 200 ready", rec.Code, body)
+	}
+}
+
+func TestCheckReadyMissingSchema(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	if err := s.db.PingContext(context.Background()); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	err := s.checkReady(context.Background(), "pgmcp_missing_schema")
+	if err == nil || !strings.Contains(err.Error(), `schema "pgmcp_missing_schema" does not exist`) {
+		t.Errorf("checkReady() = %v, want not ready because the schema is missing", err)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	rec := httptest.NewRecorder()
+	s.HealthHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ok"`) {
+		t.Errorf("/healthz = %d %s, want 200 ok", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestHTTPHandler builds the HTTP handler with stub MCP and probe
+// handlers that answer with their name.
+func newTestHTTPHandler(token string, corsOrigins []string) http.Handler {
+	stub := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+	return httpHandler(stub("mcp"), stub("healthz"), stub("readyz"), token, corsOrigins)
+}
+
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProbesBypassAuth(t *testing.T) {
+	handler := newTestHTTPHandler("s3cret", nil)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rec := serve(handler, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != path[1:] {
+			t.Errorf("%s without a token = %d %q, want the probe", path, rec.Code, rec.Body)
+		}
+	}
+
+	rec := serve(handler, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("/mcp without a token = %d, want 401 with a Bearer challenge", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	if rec := serve(handler, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("/mcp with a wrong token = %d, want 401", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	if rec := serve(handler, req); rec.Code != http.StatusOK || rec.Body.String() != "mcp" {
+		t.Errorf("/mcp with the token = %d %q, want the MCP handler", rec.Code, rec.Body)
+	}
+
+	if rec := serve(handler, httptest.NewRequest(http.MethodGet, "/other", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("/other = %d, want 404", rec.Code)
+	}
+}
+
+func TestNoAuthWithoutToken(t *testing.T) {
+	handler := newTestHTTPHandler("", nil)
+	if rec := serve(handler, httptest.NewRequest(http.MethodPost, "/mcp", nil)); rec.Code != http.StatusOK {
+		t.Errorf("/mcp without a configured token = %d, want 200", rec.Code)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	// Preflight requests carry no credentials, so they are answered before
+	// auth.
+	handler := newTestHTTPHandler("s3cret", []string{"https://app.example.com"})
+
+	req := httptest.NewRequest(http.MethodOptions, "/mcp", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := serve(handler, req)
+
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("preflight = %d %q, want an empty 200", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the request origin", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Authorization") {
+		t.Errorf("Access-Control-Allow-Headers = %q, want Authorization allowed", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	handler := newTestHTTPHandler("", []string{"https://app.example.com", " https://admin.example.com "})
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec := serve(handler, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for a disallowed origin, want none", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+
+	// Entries are trimmed.
+	req = httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	if got := serve(handler, req).Header().Get("Access-Control-Allow-Origin"); got != "https://admin.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want https://admin.example.com", got)
+	}
+}
+
+func TestCORSWildcardWhenUnset(t *testing.T) {
+	// MCP_CORS_ORIGINS unset splits into a single empty entry.
+	for _, origins := range [][]string{nil, {""}, {" ", ""}} {
+		handler := newTestHTTPHandler("", origins)
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("Origin", "https://anywhere.example.com")
+		rec := serve(handler, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("origins %q: Access-Control-Allow-Origin = %q, want *", origins, got)
+		}
+		if got := rec.Header().Get("Vary"); got != "" {
+			t.Errorf("origins %q: Vary = %q, want none for a wildcard", origins, got)
+		}
+		if rec.Body.String() != "mcp" {
+			t.Errorf("origins %q: body = %q, want the request passed on", origins, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// identifierPattern matches plain (unquoted) Postgres identifiers.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// maxIdentifierLength is Postgres's NAMEDATALEN - 1.
+const maxIdentifierLength = 63
+
+// quoteIdentifier validates name and returns it quoted for interpolation
+// into SQL text. Tools that must embed an object name in a statement (rather
+// than bind it as a parameter) go through this helper.
+//
+// A plain name must look like an unquoted identifier (letters, digits, _ and
+// $, not starting with a digit), so input such as "users; drop table x" is
+// rejected. As in SQL, a plain name is folded to lower case, so Users
+// refers to the table users. Names that need quoting, e.g. with spaces or
+// upper case, can be passed already double-quoted: "weird name".
+func quoteIdentifier(name string) (string, error) {
+	ident, err := parseIdentifier(name)
+	if err != nil {
+		return "", err
+	}
+	return pgx.Identifier{ident}.Sanitize(), nil
+}
+
+// parseIdentifier validates name and returns the identifier it denotes:
+// a plain name folded to lower case, or a quoted name with its quotes
+// removed and doubled quotes unescaped.
+func parseIdentifier(name string) (string, error) {
+	ident := name
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		ident = name[1 : len(name)-1]
+		if strings.Contains(strings.ReplaceAll(ident, `""`, ""), `"`) {
+			return "", fmt.Errorf("invalid identifier %q", name)
+		}
+		ident = strings.ReplaceAll(ident, `""`, `"`)
+		if ident == "" || strings.ContainsRune(ident, 0) {
+			return "", fmt.Errorf("invalid identifier %q", name)
+		}
+	} else if identifierPattern.MatchString(name) {
+		ident = strings.ToLower(name)
+	} else {
+		return "", fmt.Errorf("invalid identifier %q", name)
+	}
+
+	if len(ident) > maxIdentifierLength {
+		return "", fmt.Errorf("invalid identifier %q: longer than %d bytes", name, maxIdentifierLength)
+	}
+	return ident, nil
+}
+
+// splitTableName splits a "table" or "schema.table" name. An unqualified name
+// refers to the public schema. Dots inside a double-quoted part do not
+// separate names.
+func splitTableName(name string) (schema, table string, err error) {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '"':
+			quoted = !quoted
+		case '.':
+			if !quoted {
+				parts = append(parts, name[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, name[start:])
+
+	switch len(parts) {
+	case 1:
+		return defaultSchema, name, nil
+	case 2:
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("invalid table name %q", name)
+	}
+}
+
+// resolveTableName returns the schema and table identifiers named by a
+// tool's schema and table parameters, for matching against the catalog. A
+// qualified "schema.table" table takes precedence over schema. Each part is
+// parsed with parseIdentifier, so Users names the table users and "Users"
+// the table Users. An empty table stays empty, for tools where it is
+// optional.
+func resolveTableName(schema, table string) (string, string, error) {
+	if table != "" {
+		qualifiedSchema, name, err := splitTableName(table)
+		if err != nil {
+			return "", "", err
+		}
+		if name != table {
+			schema = qualifiedSchema
+		}
+		if table, err = parseIdentifier(name); err != nil {
+			return "", "", err
+		}
+	}
+	schema, err := parseIdentifier(schema)
+	if err != nil {
+		return "", "", err
+	}
+	return schema, table, nil
+}
+
+// tableIdentifier returns the table identifier of a "table" or
+// "schema.table" name already validated by quoteTableName.
+func tableIdentifier(name string) string {
+	_, table, _ := splitTableName(name)
+	ident, _ := parseIdentifier(table)
+	return ident
+}
+
+// quoteTableName validates and quotes a "table" or "schema.table" name. An
+// unqualified name refers to the public schema.
+func quoteTableName(name string) (string, error) {
+	schema, table, err := splitTableName(name)
+	if err != nil {
+		return "", err
+	}
+
+	quotedSchema, err := quoteIdentifier(schema)
+	if err != nil {
+		return "", err
+	}
+	quotedTable, err := quoteIdentifier(table)
+	if err != nil {
+		return "", err
+	}
+	return quotedSchema + "." + quotedTable, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import "testing"
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "users", want: `"users"`},
+		{name: "Users", want: `"users"`},
+		{name: "ORDER_ITEMS", want: `"order_items"`},
+		{name: "_t$1", want: `"_t$1"`},
+		{name: `"Users"`, want: `"Users"`},
+		{name: `"weird name"`, want: `"weird name"`},
+		{name: `"say ""hi"""`, want: `"say ""hi"""`},
+		{name: `"a.b"`, want: `"a.b"`},
+		{name: "users; drop table x", wantErr: true},
+		{name: `"users"; drop table x; --"`, wantErr: true},
+		{name: "weird name", wantErr: true},
+		{name: "1users", wantErr: true},
+		{name: "", wantErr: true},
+		{name: `""`, wantErr: true},
+		{name: `"unterminated`, wantErr: true},
+		{name: `"a"b"`, wantErr: true},
+		{name: "users--", wantErr: true},
+		{name: "a23456789012345678901234567890123456789012345678901234567890123", want: `"a23456789012345678901234567890123456789012345678901234567890123"`},
+		{name: "a234567890123456789012345678901234567890123456789012345678901234", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := quoteIdentifier(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("quoteIdentifier(%q) = %s, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("quoteIdentifier(%q) = %s, %v; want %s", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"Users":        "users",
+		`"Users"`:      "Users",
+		`"weird name"`: "weird name",
+		`"say ""hi"""`: `say "hi"`,
+		"created_at":   "created_at",
+		`"created_at"`: "created_at",
+	}
+	for name, want := range tests {
+		if got, err := parseIdentifier(name); err != nil || got != want {
+			t.Errorf("parseIdentifier(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestQuoteTableName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "users", want: `"public"."users"`},
+		{name: "Sales.Orders", want: `"sales"."orders"`},
+		{name: `"Sales"."Order Items"`, want: `"Sales"."Order Items"`},
+		{name: `"my.schema".t`, want: `"my.schema"."t"`},
+		{name: "a.b.c", wantErr: true},
+		{name: "users; drop table x", wantErr: true},
+		{name: "public.users; drop table x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := quoteTableName(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("quoteTableName(%q) = %s, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("quoteTableName(%q) = %s, %v; want %s", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveTableName(t *testing.T) {
+	tests := []struct {
+		schema, table         string
+		wantSchema, wantTable string
+		wantErr               bool
+	}{
+		{schema: "public", table: "Users", wantSchema: "public", wantTable: "users"},
+		{schema: "public", table: `"Users"`, wantSchema: "public", wantTable: "Users"},
+		{schema: "Sales", table: "orders", wantSchema: "sales", wantTable: "orders"},
+		{schema: "ignored", table: `"Sales"."Order Items"`, wantSchema: "Sales", wantTable: "Order Items"},
+		{schema: "sales", table: `"a.b"`, wantSchema: "sales", wantTable: "a.b"},
+		{schema: "Sales", table: "", wantSchema: "sales", wantTable: ""},
+		{schema: "public", table: "a.b.c", wantErr: true},
+		{schema: "public", table: "users; drop table x", wantErr: true},
+		{schema: "bad schema", table: "users", wantErr: true},
+	}
+	for _, tt := range tests {
+		schema, table, err := resolveTableName(tt.schema, tt.table)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("resolveTableName(%q, %q) = %q, %q; want an error", tt.schema, tt.table, schema, table)
+			}
+			continue
+		}
+		if err != nil || schema != tt.wantSchema || table != tt.wantTable {
+			t.Errorf("resolveTableName(%q, %q) = %q, %q, %v; want %q, %q",
+				tt.schema, tt.table, schema, table, err, tt.wantSchema, tt.wantTable)
+		}
+	}
+}
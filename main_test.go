@@ -0,0 +1,883 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// testDatabaseEnv names the database the integration tests run against.
+// Tests that need a database are skipped when it is unset, e.g.
+//
+//	PGMCP_TEST_DATABASE_URL=postgres://postgres@localhost/postgres?sslmode=disable go test ./...
+const testDatabaseEnv = "PGMCP_TEST_DATABASE_URL"
+
+// newTestServer connects to the test database with opts.
+func newTestServer(t *testing.T, opts ServerOptions) *PostgresServer {
+	t.Helper()
+	return newTestServerWithParams(t, opts)
+}
+
+// newTestServerWithParams connects to the test database with opts, sending
+// params, such as "search_path=x", as session settings.
+func newTestServerWithParams(t *testing.T, opts ServerOptions, params ...string) *PostgresServer {
+	t.Helper()
+	url := os.Getenv(testDatabaseEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+	for _, param := range params {
+		switch {
+		case !strings.Contains(url, "://"):
+			url += " " + param
+		case strings.Contains(url, "?"):
+			url += "&" + param
+		default:
+			url += "?" + param
+		}
+	}
+	s, err := NewPostgresServer(DatabaseConfig{URL: url}, opts)
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testSchema creates an empty schema for the calling test, dropped when the
+// test ends, and returns its name.
+func testSchema(t *testing.T, s *PostgresServer) string {
+	t.Helper()
+	schema := fmt.Sprintf("pgmcp_test_%d", time.Now().UnixNano())
+	mustExec(t, s, "CREATE SCHEMA "+schema)
+	t.Cleanup(func() {
+		s.db.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+	return schema
+}
+
+// mustExec runs setup statements on the pool directly, outside the
+// read-only transactions the tools use.
+func mustExec(t *testing.T, s *PostgresServer, statements ...string) {
+	t.Helper()
+	for _, stmt := range statements {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+// callTool calls handler with args and returns the text of its result and
+// whether it is an error. A Go error returned by the handler counts as an
+// error result with the error's text.
+func callTool(t *testing.T, handler server.ToolHandlerFunc, args map[string]interface{}) (string, bool) {
+	t.Helper()
+	var req mcp.CallToolRequest
+	req.Params.Arguments = args
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		return err.Error(), true
+	}
+	return resultText(result), result.IsError
+}
+
+// callToolJSON calls handler, fails the test on an error result and decodes
+// the result text into v.
+func callToolJSON(t *testing.T, handler server.ToolHandlerFunc, args map[string]interface{}, v interface{}) {
+	t.Helper()
+	text, isError := callTool(t, handler, args)
+	if isError {
+		t.Fatalf("tool failed: %s", text)
+	}
+	if err := json.Unmarshal([]byte(text), v); err != nil {
+		t.Fatalf("failed to decode %q: %v", text, err)
+	}
+}
+
+func TestQueryScalar(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	var got struct {
+		Column string      `json:"column"`
+		Type   string      `json:"type"`
+		Value  json.Number `json:"value"`
+	}
+	callToolJSON(t, s.QueryScalar, map[string]interface{}{
+		"query": "SELECT count(*) AS n FROM generate_series(1, 42)",
+	}, &got)
+	if got.Column != "n" || got.Type != "int8" || got.Value != "42" {
+		t.Errorf("got %+v, want column n of type int8 with value 42", got)
+	}
+}
+
+func TestQueryScalarRejectsMultipleColumns(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	text, isError := callTool(t, s.QueryScalar, map[string]interface{}{"query": "SELECT 1 AS a, 2 AS b"})
+	if !isError || !strings.Contains(text, "exactly one column, got 2 (a, b)") {
+		t.Errorf("got %q (error: %v), want an error naming both columns", text, isError)
+	}
+}
+
+func TestQueryScalarRejectsMultipleRows(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	text, isError := callTool(t, s.QueryScalar, map[string]interface{}{"query": "SELECT generate_series(1, 2)"})
+	if !isError || !strings.Contains(text, "exactly one row") {
+		t.Errorf("got %q (error: %v), want a row count error", text, isError)
+	}
+}
+
+func TestConnStringMultipleHosts(t *testing.T) {
+	c := DatabaseConfig{
+		Host:               "db1.example.com, db2.example.com",
+		Ports:              []int{5432, 5433},
+		User:               "app",
+		Password:           "secret",
+		DBName:             "shop",
+		SSLMode:            "disable",
+		TargetSessionAttrs: "prefer-standby",
+	}
+	hosts, err := c.hostPorts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "host='db1.example.com,db2.example.com' port=5432,5433 user='app' dbname='shop' " +
+		"sslmode='disable' target_session_attrs='prefer-standby'"
+	if got := c.connString(hosts); got != want {
+		t.Errorf("connString() =\n%s\nwant\n%s", got, want)
+	}
+
+	config, err := c.pgxConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{fmt.Sprintf("%s:%d", config.Host, config.Port)}
+	for _, fallback := range config.Fallbacks {
+		got = append(got, fmt.Sprintf("%s:%d", fallback.Host, fallback.Port))
+	}
+	if strings.Join(got, " ") != "db1.example.com:5432 db2.example.com:5433" {
+		t.Errorf("pgx hosts = %v, want db1.example.com:5432 and db2.example.com:5433", got)
+	}
+}
+
+func TestHostPortsCountMismatch(t *testing.T) {
+	c := DatabaseConfig{Host: "a,b,c", Ports: []int{5432, 5433}}
+	if _, err := c.hostPorts(); err == nil || !strings.Contains(err.Error(), "3 hosts but 2 ports") {
+		t.Errorf("hostPorts() error = %v, want a host/port count mismatch", err)
+	}
+}
+
+func TestHostPortsSharedPort(t *testing.T) {
+	c := DatabaseConfig{Host: "a,b", Port: 6432}
+	hosts, err := c.hostPorts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[0] != (hostPort{"a", 6432}) || hosts[1] != (hostPort{"b", 6432}) {
+		t.Errorf("hostPorts() = %v, want both hosts on port 6432", hosts)
+	}
+}
+
+func TestGetEnvInt(t *testing.T) {
+	const key = "PGMCP_TEST_ENV_INT"
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+	if got := getEnvInt(key, 7); got != 7 {
+		t.Errorf("getEnvInt() unset = %d, want the default", got)
+	}
+
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 7},
+		{"42", 42},
+		{" 42 ", 42},
+		{"-3", -3},
+		{"forty", 7},
+		{"4.5", 7},
+	}
+	for _, tt := range tests {
+		t.Setenv(key, tt.value)
+		if got := getEnvInt(key, 7); got != tt.want {
+			t.Errorf("getEnvInt(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestGetEnvPorts(t *testing.T) {
+	const key = "PGMCP_TEST_ENV_PORTS"
+	tests := []struct {
+		value string
+		want  []int
+	}{
+		{"", []int{5432}},
+		{"6432", []int{6432}},
+		{"5432, 5433", []int{5432, 5433}},
+		{"postgres", []int{5432}},
+		{"5432,", []int{5432}},
+	}
+	for _, tt := range tests {
+		t.Setenv(key, tt.value)
+		if got := getEnvPorts(key, 5432); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getEnvPorts(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIntrospectionSourcesAreEquivalent(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TYPE "+schema+".mood AS ENUM ('sad', 'ok', 'happy')",
+		"CREATE DOMAIN "+schema+".code AS varchar(8)",
+		`CREATE TABLE `+schema+`.seeded (
+			id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			name varchar(50) NOT NULL DEFAULT 'anon',
+			initial char(1),
+			price numeric(10,2),
+			ratio numeric,
+			tags text[],
+			mood `+schema+`.mood,
+			code `+schema+`.code,
+			created_at timestamptz DEFAULT now(),
+			price_with_tax numeric GENERATED ALWAYS AS (price * 1.2) STORED
+		)`,
+		"COMMENT ON COLUMN "+schema+".seeded.name IS 'display name'",
+		"CREATE TABLE "+schema+".other (id int)",
+	)
+
+	byInformationSchema := newTestServer(t, ServerOptions{IntrospectionSource: introspectionInformationSchema})
+	byCatalog := newTestServer(t, ServerOptions{IntrospectionSource: introspectionPgCatalog})
+
+	want, err := byInformationSchema.describeColumns(context.Background(), schema, "seeded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := byCatalog.describeColumns(context.Background(), schema, "seeded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 10 {
+		t.Fatalf("information_schema returned %d columns, want 10", len(want))
+	}
+	wantJSON, _ := json.MarshalIndent(want, "", "  ")
+	gotJSON, _ := json.MarshalIndent(got, "", "  ")
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("pg_catalog columns:\n%s\ninformation_schema columns:\n%s", gotJSON, wantJSON)
+	}
+
+	var tablesA, tablesB []string
+	callToolJSON(t, byInformationSchema.ListTables, map[string]interface{}{"schema": schema}, &tablesA)
+	callToolJSON(t, byCatalog.ListTables, map[string]interface{}{"schema": schema}, &tablesB)
+	sort.Strings(tablesA)
+	sort.Strings(tablesB)
+	if strings.Join(tablesA, ",") != "other,seeded" || strings.Join(tablesB, ",") != "other,seeded" {
+		t.Errorf("list_tables = %v (information_schema) and %v (pg_catalog), want [other seeded]", tablesA, tablesB)
+	}
+}
+
+func TestFormatNDJSON(t *testing.T) {
+	rows := []map[string]interface{}{{"id": 1, "name": "a"}, {"id": 2, "name": "b\nc"}}
+	want := "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\\nc\"}\n"
+	if got := formatNDJSON(rows); got != want {
+		t.Errorf("formatNDJSON() = %q, want %q", got, want)
+	}
+	if got := formatNDJSON(nil); got != "" {
+		t.Errorf("formatNDJSON(nil) = %q, want empty", got)
+	}
+}
+
+func TestExecuteQueryNDJSONLineCount(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	text, isError := callTool(t, s.ExecuteQuery, map[string]interface{}{
+		"query":  "SELECT g AS n, 'line' || g AS label FROM generate_series(1, 25) g",
+		"format": "ndjson",
+	})
+	if isError {
+		t.Fatal(text)
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) != 25 {
+		t.Fatalf("got %d lines, want one per row (25)", len(lines))
+	}
+	for i, line := range lines {
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("line %d is not a JSON object: %q", i+1, line)
+		}
+		if _, ok := row["columns"]; ok {
+			t.Fatalf("line %d holds result metadata: %q", i+1, line)
+		}
+	}
+}
+
+func TestExecuteQueryStreamTruncationMarker(t *testing.T) {
+	s := newTestServer(t, ServerOptions{MaxRows: 3})
+
+	lines := func(args map[string]interface{}) []string {
+		t.Helper()
+		args["stream"] = true
+		text, isError := callTool(t, s.ExecuteQuery, args)
+		if isError {
+			t.Fatal(text)
+		}
+		return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+
+	got := lines(map[string]interface{}{"query": "SELECT g FROM generate_series(1, 3) g"})
+	if len(got) != 3 || got[2] != `{"g":3}` {
+		t.Errorf("got %q, want the three rows and no marker", got)
+	}
+
+	got = lines(map[string]interface{}{"query": "SELECT g FROM generate_series(1, 10) g"})
+	if len(got) != 4 || got[3] != `{"truncated":true,"max_rows":3}` {
+		t.Errorf("got %q, want three rows and a truncation marker", got)
+	}
+
+	got = lines(map[string]interface{}{"query": "SELECT g FROM generate_series(1, 10) g ORDER BY g", "limit": 2, "offset": 4})
+	if len(got) != 3 || got[0] != `{"g":5}` || got[2] != `{"truncated":true,"max_rows":2,"next_offset":6}` {
+		t.Errorf("got %q, want a page of two rows and the next offset", got)
+	}
+}
+
+// testSession is a client session that is always ready for notifications.
+type testSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) Initialize()                                         {}
+func (s *testSession) Initialized() bool                                   { return true }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s *testSession) SessionID() string                                   { return "test" }
+
+func TestRowStream(t *testing.T) {
+	line := strings.Repeat("x", 98) + "\n"
+	const lines = 3 * streamChunkSize / 99
+
+	srv := server.NewMCPServer("test", "0")
+	srv.AddTool(mcp.NewTool("export"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		w := newRowStream(ctx, req)
+		if w == nil {
+			return mcp.NewToolResultText("not streamed"), nil
+		}
+		for i := 0; i < lines; i++ {
+			w.Write([]byte(line))
+		}
+		if err := w.Flush(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText("streamed"), nil
+	})
+
+	// A one-slot channel read slowly makes the stream wait for the client
+	// rather than drop chunks.
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 1)}
+	ctx := srv.WithContext(context.Background(), session)
+	var messages []string
+	var progress []float64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := range session.notifications {
+			time.Sleep(5 * time.Millisecond)
+			fields := n.Params.AdditionalFields
+			if n.Method != "notifications/progress" || fields["progressToken"] != "export-1" {
+				t.Errorf("unexpected notification %+v", n)
+			}
+			messages = append(messages, fields["message"].(string))
+			progress = append(progress, float64(fields["progress"].(int)))
+		}
+	}()
+
+	call := func(meta string) string {
+		t.Helper()
+		message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"export","arguments":{}` + meta + `}}`
+		response, _ := json.Marshal(srv.HandleMessage(ctx, []byte(message)))
+		return string(response)
+	}
+	if got := call(""); !strings.Contains(got, "not streamed") {
+		t.Errorf("without a progress token got %s, want no stream", got)
+	}
+	if got := call(`,"_meta":{"progressToken":"export-1"}`); !strings.Contains(got, `"streamed"`) {
+		t.Fatalf("with a progress token got %s", got)
+	}
+	close(session.notifications)
+	<-done
+
+	if len(messages) < 3 {
+		t.Errorf("got %d notifications, want the rows sent in several chunks", len(messages))
+	}
+	if got := strings.Join(messages, ""); got != strings.Repeat(line, lines) {
+		t.Errorf("streamed %d bytes, want %d lines of %d", len(got), lines, len(line))
+	}
+	if !sort.Float64sAreSorted(progress) || progress[len(progress)-1] != lines {
+		t.Errorf("progress = %v, want it to rise to %d", progress, lines)
+	}
+}
+
+func TestExecuteQueryStreamsToProgressToken(t *testing.T) {
+	s := newTestServer(t, ServerOptions{MaxRows: 3, DefaultLimit: 2})
+
+	srv := server.NewMCPServer("test", "0")
+	srv.AddTool(mcp.NewTool("postgres_query"), s.ExecuteQuery)
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 100)}
+	ctx := srv.WithContext(context.Background(), session)
+
+	message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"postgres_query",` +
+		`"arguments":{"query":"SELECT g FROM generate_series(1, 10) g","stream":true},"_meta":{"progressToken":1}}}`
+	response, _ := json.Marshal(srv.HandleMessage(ctx, []byte(message)))
+	if !strings.Contains(string(response), `{\"count\":10,\"streamed\":true}`) {
+		t.Fatalf("got %s, want all ten rows streamed", response)
+	}
+	close(session.notifications)
+
+	var streamed strings.Builder
+	for n := range session.notifications {
+		streamed.WriteString(n.Params.AdditionalFields["message"].(string))
+	}
+	// Neither DB_MAX_ROWS nor DB_DEFAULT_LIMIT caps a streamed export.
+	got := strings.Split(strings.TrimSuffix(streamed.String(), "\n"), "\n")
+	if len(got) != 10 || got[9] != `{"g":10}` {
+		t.Errorf("streamed %q, want the ten rows", got)
+	}
+}
+
+func TestTableToolsIdentifierCase(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".users (id int PRIMARY KEY)",
+		`CREATE TABLE `+schema+`."Users" (id int, mixed boolean)`,
+		`CREATE INDEX mixed_idx ON `+schema+`."Users" (mixed)`,
+	)
+
+	for table, wantMixed := range map[string]bool{
+		"users":                  false,
+		schema + ".USERS":        false,
+		`"Users"`:                true,
+		schema + `."Users"`:      true,
+		`"` + schema + `".users`: false,
+	} {
+		args := map[string]interface{}{"schema": schema, "table": table}
+
+		var description TableDescription
+		callToolJSON(t, s.DescribeTable, args, &description)
+		if got := len(description.Columns) == 2; got != wantMixed {
+			t.Errorf("describe_table %s = %+v, want the mixed-case table: %v", table, description, wantMixed)
+		}
+
+		var indexes []IndexInfo
+		callToolJSON(t, s.ListIndexes, args, &indexes)
+		if len(indexes) != 1 || (indexes[0].Name == "mixed_idx") != wantMixed {
+			t.Errorf("list_indexes %s = %+v, want the index of the mixed-case table: %v", table, indexes, wantMixed)
+		}
+
+		ddl, isError := callTool(t, s.GetTableDDL, args)
+		if isError || strings.Contains(ddl, "mixed") != wantMixed {
+			t.Errorf("get_table_ddl %s = %q, want the mixed-case table: %v", table, ddl, wantMixed)
+		}
+
+		var size TableSize
+		callToolJSON(t, s.TableSize, args, &size)
+		if strings.Contains(size.Table, `"Users"`) != wantMixed {
+			t.Errorf("table_size %s = %+v, want the mixed-case table: %v", table, size, wantMixed)
+		}
+	}
+}
+
+func TestExecuteQueryRowCount(t *testing.T) {
+	s := newTestServer(t, ServerOptions{MaxRows: 5})
+
+	type counts struct {
+		Count           int  `json:"count"`
+		Truncated       bool `json:"truncated"`
+		RowCount        *int `json:"row_count"`
+		RowCountAtLeast *int `json:"row_count_at_least"`
+	}
+	check := func(name string, got counts, count int, rowCount, atLeast *int) {
+		t.Helper()
+		if got.Count != count || !reflect.DeepEqual(got.RowCount, rowCount) || !reflect.DeepEqual(got.RowCountAtLeast, atLeast) {
+			t.Errorf("%s: got count %d, row_count %v, row_count_at_least %v; want %d, %v, %v",
+				name, got.Count, got.RowCount, got.RowCountAtLeast, count, rowCount, atLeast)
+		}
+	}
+	intp := func(n int) *int { return &n }
+
+	var got counts
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{"query": "SELECT g FROM generate_series(1, 3) g"}, &got)
+	check("under the cap", got, 3, intp(3), nil)
+
+	// Rows past the cap are never read: the division by zero at row 20
+	// would otherwise fail the query.
+	got = counts{}
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{"query": "SELECT 1 / (20 - g) FROM generate_series(1, 100) g"}, &got)
+	check("capped", got, 5, nil, intp(6))
+
+	// The LIMIT wrapper reads one row past the limit; it is not counted
+	// as a row of the result.
+	s = newTestServer(t, ServerOptions{DefaultLimit: 10})
+	got = counts{}
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{"query": "SELECT g FROM generate_series(1, 10) g"}, &got)
+	check("limit not reached", got, 10, intp(10), nil)
+	got = counts{}
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{"query": "SELECT g FROM generate_series(1, 100) g"}, &got)
+	check("limit reached", got, 10, nil, intp(11))
+}
+
+func TestLimitColumns(t *testing.T) {
+	r := QueryResult{
+		Columns: []string{"a", "b", "c", "d"},
+		Rows:    []map[string]interface{}{{"a": 1, "b": 2, "c": 3, "d": 4}},
+		Count:   1,
+	}
+	r.limitColumns(2)
+
+	if strings.Join(r.Columns, ",") != "a,b" || strings.Join(r.OmittedColumns, ",") != "c,d" {
+		t.Errorf("columns = %v, omitted = %v, want [a b] and [c d]", r.Columns, r.OmittedColumns)
+	}
+	if len(r.Rows[0]) != 2 || r.Rows[0]["a"] != 1 || r.Rows[0]["b"] != 2 {
+		t.Errorf("row = %v, want only a and b", r.Rows[0])
+	}
+	if !strings.Contains(r.Note, "4 columns; only the first 2") {
+		t.Errorf("note = %q", r.Note)
+	}
+
+	narrow := QueryResult{Columns: []string{"a"}}
+	narrow.limitColumns(2)
+	if narrow.OmittedColumns != nil || narrow.Note != "" {
+		t.Errorf("a result within the limit was changed: %+v", narrow)
+	}
+}
+
+func TestExecuteQueryMaxColumns(t *testing.T) {
+	s := newTestServer(t, ServerOptions{MaxColumns: 3})
+
+	var cols []string
+	for i := 1; i <= 40; i++ {
+		cols = append(cols, fmt.Sprintf("%d AS c%02d", i, i))
+	}
+	var got QueryResult
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{"query": "SELECT " + strings.Join(cols, ", ")}, &got)
+
+	if strings.Join(got.Columns, ",") != "c01,c02,c03" {
+		t.Errorf("columns = %v, want the first 3", got.Columns)
+	}
+	if len(got.OmittedColumns) != 37 || got.OmittedColumns[0] != "c04" || got.OmittedColumns[36] != "c40" {
+		t.Errorf("omitted_columns = %v, want c04 to c40", got.OmittedColumns)
+	}
+	if len(got.Rows) != 1 || len(got.Rows[0]) != 3 {
+		t.Errorf("rows = %v, want one row of 3 columns", got.Rows)
+	}
+	if !strings.Contains(got.Note, "40 columns; only the first 3") {
+		t.Errorf("note = %q", got.Note)
+	}
+}
+
+func TestExecuteQueryDryRun(t *testing.T) {
+	s := &PostgresServer{opts: ServerOptions{DefaultLimit: 10}}
+
+	var got DryRunResult
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{
+		"query":   "SELECT g /* pick */ FROM generate_series(1, 100) g -- all of them\n;",
+		"tenant":  "acme",
+		"dry_run": true,
+	}, &got)
+
+	if !got.DryRun || len(got.Statements) != 4 {
+		t.Fatalf("got %+v, want BEGIN, the tenant setting, the query and ROLLBACK", got)
+	}
+	if got.Statements[0].SQL != "BEGIN READ ONLY" || got.Statements[3].SQL != "ROLLBACK" {
+		t.Errorf("statements = %+v, want the query inside a read-only transaction", got.Statements)
+	}
+	if tenant := got.Statements[1]; tenant.SQL != setTenantSQL || fmt.Sprint(tenant.Params) != "[app.current_tenant acme]" {
+		t.Errorf("tenant statement = %+v", tenant)
+	}
+
+	query := got.Statements[2].SQL
+	if !strings.HasPrefix(query, "SELECT * FROM (SELECT g") || !strings.HasSuffix(query, ") _sub LIMIT 11") {
+		t.Errorf("query = %q, want it wrapped with the default LIMIT plus one", query)
+	}
+	if strings.Contains(query, "pick") || strings.Contains(query, "all of them") || strings.Contains(query, ";") {
+		t.Errorf("query = %q, want comments and the terminator stripped", query)
+	}
+}
+
+func TestExecuteQueryCapsInSQL(t *testing.T) {
+	s := &PostgresServer{opts: ServerOptions{MaxRows: 5, DefaultLimit: 10}}
+
+	executed := func(args map[string]interface{}) string {
+		t.Helper()
+		args["dry_run"] = true
+		var got DryRunResult
+		callToolJSON(t, s.ExecuteQuery, args, &got)
+		return got.Statements[1].SQL
+	}
+
+	tests := []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"query": "SELECT g FROM generate_series(1, 100) g"}, "SELECT * FROM (SELECT g FROM generate_series(1, 100) g) _sub LIMIT 6"},
+		{map[string]interface{}{"query": "SELECT count(*) FROM generate_series(1, 100) g", "max_rows": 3}, "SELECT * FROM (SELECT count(*) FROM generate_series(1, 100) g) _sub LIMIT 4"},
+		{map[string]interface{}{"query": "SELECT count(*) FROM t", "max_rows": 0}, "SELECT count(*) FROM t"},
+		{map[string]interface{}{"query": "SELECT g FROM t", "limit": 2, "offset": 4}, "SELECT * FROM (SELECT g FROM t) _page LIMIT 3 OFFSET 4"},
+		{map[string]interface{}{"query": "SELECT g FROM t", "limit": 4, "max_rows": 2}, "SELECT * FROM (SELECT g FROM t) _page LIMIT 3"},
+	}
+	for _, tt := range tests {
+		if got := executed(tt.args); got != tt.want {
+			t.Errorf("%v: executed %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestQueryJSONNested(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".orders (id int PRIMARY KEY, customer text)",
+		"CREATE TABLE "+schema+".order_items (order_id int, sku text, qty int)",
+		"INSERT INTO "+schema+".orders VALUES (1, 'ada'), (2, 'bob')",
+		"INSERT INTO "+schema+".order_items VALUES (1, 'pen', 2), (1, 'ink', 1), (2, 'pad', 5)",
+	)
+
+	var got []struct {
+		ID       int    `json:"id"`
+		Customer string `json:"customer"`
+		Items    []struct {
+			SKU string `json:"sku"`
+			Qty int    `json:"qty"`
+		} `json:"items"`
+	}
+	callToolJSON(t, s.QueryJSON, map[string]interface{}{
+		"query": `SELECT o.id, o.customer,
+			(SELECT json_agg(json_build_object('sku', i.sku, 'qty', i.qty) ORDER BY i.sku)
+			 FROM ` + schema + `.order_items i WHERE i.order_id = o.id) AS items
+			FROM ` + schema + `.orders o ORDER BY o.id;`,
+	}, &got)
+
+	if len(got) != 2 || got[0].Customer != "ada" || got[1].Customer != "bob" {
+		t.Fatalf("got %+v, want orders for ada and bob", got)
+	}
+	if len(got[0].Items) != 2 || got[0].Items[0].SKU != "ink" || got[0].Items[1].Qty != 2 {
+		t.Errorf("ada's items = %+v, want ink and 2 pens", got[0].Items)
+	}
+	if len(got[1].Items) != 1 || got[1].Items[0].SKU != "pad" || got[1].Items[0].Qty != 5 {
+		t.Errorf("bob's items = %+v, want 5 pads", got[1].Items)
+	}
+
+	text, isError := callTool(t, s.QueryJSON, map[string]interface{}{"query": "SELECT 1 WHERE false"})
+	if isError || text != "[]" {
+		t.Errorf("empty result = %q (error: %v), want []", text, isError)
+	}
+}
+
+func TestDescribeColumnsIdentityAndGenerated(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s, `CREATE TABLE `+schema+`.line_items (
+		id bigint GENERATED ALWAYS AS IDENTITY,
+		seq int GENERATED BY DEFAULT AS IDENTITY,
+		qty int NOT NULL DEFAULT 1,
+		price numeric,
+		total numeric GENERATED ALWAYS AS (qty * price) STORED
+	)`)
+
+	for _, source := range []string{introspectionInformationSchema, introspectionPgCatalog} {
+		t.Run(source, func(t *testing.T) {
+			s := newTestServer(t, ServerOptions{IntrospectionSource: source})
+			columns, err := s.describeColumns(context.Background(), schema, "line_items")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]ColumnInfo)
+			for _, c := range columns {
+				got[c.Column] = c
+			}
+
+			if c := got["id"]; !c.IsIdentity || c.IdentityGeneration != "ALWAYS" || c.IsGenerated {
+				t.Errorf("id = %+v, want an ALWAYS identity column", c)
+			}
+			if c := got["seq"]; !c.IsIdentity || c.IdentityGeneration != "BY DEFAULT" || c.IsGenerated {
+				t.Errorf("seq = %+v, want a BY DEFAULT identity column", c)
+			}
+			if c := got["qty"]; c.IsIdentity || c.IsGenerated || c.Default == nil || *c.Default != "1" {
+				t.Errorf("qty = %+v, want a plain column with default 1", c)
+			}
+			if c := got["total"]; !c.IsGenerated || c.IsIdentity || !strings.Contains(c.GenerationExpression, "qty") {
+				t.Errorf("total = %+v, want a generated column computed from qty", c)
+			}
+		})
+	}
+}
+
+func TestNormalizeValueNumeric(t *testing.T) {
+	tests := []struct {
+		val    interface{}
+		dbType string
+		want   string
+	}{
+		{"42.00", "NUMERIC", "42.00"},
+		{"-0.5", "NUMERIC", "-0.5"},
+		{"123456789012345678901234567890.12", "NUMERIC", "123456789012345678901234567890.12"},
+		{"NaN", "NUMERIC", `"NaN"`},
+		{int64(9007199254740993), "INT8", "9007199254740993"},
+		{float64(0.1), "FLOAT8", "0.1"},
+	}
+	for _, tt := range tests {
+		got, _ := json.Marshal(normalizeValue(tt.val, tt.dbType))
+		if string(got) != tt.want {
+			t.Errorf("normalizeValue(%v, %s) marshals to %s, want %s", tt.val, tt.dbType, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteQueryNumericTypes(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+
+	text, isError := callTool(t, s.ExecuteQuery, map[string]interface{}{
+		"query": "SELECT 42::numeric(10,2) AS price, 9007199254740993::bigint AS big, 0.1::double precision AS ratio",
+	})
+	if isError {
+		t.Fatal(text)
+	}
+	for _, want := range []string{`"price":42.00`, `"big":9007199254740993`, `"ratio":0.1`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result %s does not contain %s", text, want)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	ts := time.Date(2024, 1, 15, 9, 30, 0, 250000000, berlin)
+	tests := map[string]string{
+		"TIMESTAMPTZ": "2024-01-15T09:30:00.25+01:00",
+		"TIMESTAMP":   "2024-01-15T09:30:00.25",
+		"DATE":        "2024-01-15",
+	}
+	for dbType, want := range tests {
+		if got := formatTime(ts, dbType); got != want {
+			t.Errorf("formatTime(%s) = %q, want %q", dbType, got, want)
+		}
+	}
+}
+
+func TestExecuteQueryTimestamptzInSessionZone(t *testing.T) {
+	url := os.Getenv(testDatabaseEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+	s, err := NewPostgresServer(DatabaseConfig{URL: url, TimeZone: "America/New_York"}, ServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var got QueryResult
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{
+		"query": `SELECT timestamptz '2024-07-01 12:00:00+00' AS tstz,
+			timestamp '2024-07-01 12:00:00' AS ts,
+			date '2024-07-01' AS d,
+			current_setting('TimeZone') AS zone`,
+	}, &got)
+
+	row := got.Rows[0]
+	want := map[string]interface{}{
+		"tstz": "2024-07-01T08:00:00-04:00",
+		"ts":   "2024-07-01T12:00:00",
+		"d":    "2024-07-01",
+		"zone": "America/New_York",
+	}
+	for column, w := range want {
+		if row[column] != w {
+			t.Errorf("%s = %v, want %v", column, row[column], w)
+		}
+	}
+}
+
+// nastyPasswords need quoting or escaping in connection strings and URLs.
+var nastyPasswords = []string{`p@ss word'1`, `it's a secret`, `back\slash`, `'quoted'`, `a:b@c/d?e#f`, `trailing\`, `semi;colon=x`, `ünïcode €`}
+
+func TestPasswordRoundTrip(t *testing.T) {
+	for _, password := range nastyPasswords {
+		c := DatabaseConfig{Host: "db.example.com", Port: 5432, User: "app", Password: password, DBName: "shop", SSLMode: "disable"}
+		config, err := c.pgxConfig()
+		if err != nil {
+			t.Errorf("password %q: %v", password, err)
+			continue
+		}
+		if config.Password != password || config.User != "app" || config.Database != "shop" {
+			t.Errorf("password %q parsed as user %q, password %q, database %q", password, config.User, config.Password, config.Database)
+		}
+	}
+}
+
+func TestDatabaseURLPasswordRoundTrip(t *testing.T) {
+	for _, password := range nastyPasswords {
+		u := fmt.Sprintf("postgres://%s@db.example.com:5432/shop?sslmode=disable", url.UserPassword("app", password))
+		config, err := DatabaseConfig{URL: u}.pgxConfig()
+		if err != nil {
+			t.Errorf("password %q: %v", password, err)
+			continue
+		}
+		if config.Password != password || config.User != "app" || config.Database != "shop" {
+			t.Errorf("password %q parsed as user %q, password %q, database %q", password, config.User, config.Password, config.Database)
+		}
+	}
+}
+
+func TestPasswordNotInErrors(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "missing.pem")
+	failures := func(password string) []string {
+		var texts []string
+		// A missing CA file fails while parsing the settings.
+		c := DatabaseConfig{
+			Host: "db.example.com", Port: 5432, User: "app", Password: password, DBName: "shop",
+			SSLMode: "verify-full", SSLRootCert: caFile,
+		}
+		_, err := c.pgxConfig()
+		texts = append(texts, fmt.Sprint(err))
+
+		// Nothing listens on port 1, so connecting fails.
+		c = DatabaseConfig{Host: "127.0.0.1", Port: 1, User: "app", Password: password, DBName: "shop", SSLMode: "disable"}
+		_, err = NewPostgresServer(c, ServerOptions{})
+		texts = append(texts, fmt.Sprint(err))
+
+		u := fmt.Sprintf("postgres://%s@127.0.0.1:1/shop?sslmode=disable", url.UserPassword("app", password))
+		_, err = NewPostgresServer(DatabaseConfig{URL: u}, ServerOptions{})
+		texts = append(texts, fmt.Sprint(err))
+		return texts
+	}
+
+	// An error that does not mention the password reads the same whatever
+	// it is; comparing whole messages also catches partly redacted ones.
+	want := failures("plain")
+	for _, password := range nastyPasswords {
+		got := failures(password)
+		for i := range want {
+			if got[i] == "<nil>" {
+				t.Errorf("password %q: case %d did not fail", password, i)
+			} else if got[i] != want[i] {
+				t.Errorf("password %q leaks into the error:\n%s\nwant\n%s", password, got[i], want[i])
+			}
+		}
+	}
+}
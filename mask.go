@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maskToken replaces the values of masked columns
+const maskToken = "***"
+
+// columnMask names a column whose values the tools mask. An empty table
+// masks the column whatever table it comes from.
+type columnMask struct {
+	table  string
+	column string
+}
+
+// parseColumnMasks parses a comma-separated list of "column" or
+// "table.column" names.
+func parseColumnMasks(value string) []columnMask {
+	var masks []columnMask
+	for _, part := range strings.Split(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		if table, column, ok := strings.Cut(part, "."); ok {
+			masks = append(masks, columnMask{table: table, column: column})
+		} else {
+			masks = append(masks, columnMask{column: part})
+		}
+	}
+	return masks
+}
+
+// maskedColumns returns the result columns of query, run with args, whose
+// values must be masked. They are derived from the plan: a result column is
+// masked when its output expression reads a masked column of a table, e.g.
+// "ssn AS x" or "upper(ssn)", or reads a subquery, CTE or function result
+// while the query reads a masked column anywhere. A column named like a
+// masked one is masked too. If the plan cannot be read, only the names are
+// matched, and "table.column" masks apply whatever the query reads.
+func (s *PostgresServer) maskedColumns(ctx context.Context, query string, columns []string, args ...interface{}) map[string]bool {
+	if len(s.opts.MaskColumns) == 0 {
+		return nil
+	}
+
+	var plan *planNode
+	if raw, err := s.queryPlan(ctx, query, args...); err == nil {
+		if p, err := parsePlan(raw); err == nil {
+			plan = &p
+		}
+	}
+
+	masked := make(map[string]bool)
+	var tables map[string]bool
+	if plan != nil {
+		for i, isMasked := range s.planMaskedOutputs(*plan, len(columns)) {
+			if isMasked {
+				masked[columns[i]] = true
+			}
+		}
+		tables = make(map[string]bool)
+		plan.walk(func(n planNode) {
+			if n.RelationName != "" {
+				tables[strings.ToLower(n.RelationName)] = true
+			}
+		})
+	}
+	for _, column := range columns {
+		for _, m := range s.opts.MaskColumns {
+			if m.column == strings.ToLower(column) && (m.table == "" || tables == nil || tables[m.table]) {
+				masked[column] = true
+			}
+		}
+	}
+	return masked
+}
+
+// planMaskedOutputs reports, for each of the n output columns of plan,
+// whether its expression may carry a masked value (see maskedColumns).
+// When the top node does not list n outputs, as for a UNION, every column
+// is masked if the plan reads a masked column at all.
+func (s *PostgresServer) planMaskedOutputs(plan planNode, n int) []bool {
+	// Expressions refer to tables by alias; other aliases name subqueries,
+	// CTEs, function scans and the like, whose columns cannot be traced.
+	// An alias may be reused for different tables in different subqueries.
+	tables := make(map[string][]string)
+	opaque := make(map[string]bool)
+	plan.walk(func(node planNode) {
+		switch {
+		case node.RelationName != "":
+			tables[node.Alias] = append(tables[node.Alias], node.RelationName)
+		case node.Alias != "":
+			opaque[node.Alias] = true
+		}
+	})
+
+	readsMasked := func(expr string) (direct, viaOpaque bool) {
+		for _, ref := range columnRefs(expr) {
+			for _, table := range tables[ref[0]] {
+				if s.columnMasked(table, ref[1]) {
+					direct = true
+				}
+			}
+			if opaque[ref[0]] {
+				viaOpaque = true
+			}
+		}
+		return direct, viaOpaque
+	}
+
+	anyMasked := false
+	plan.walk(func(node planNode) {
+		for _, expr := range node.Output {
+			if direct, _ := readsMasked(expr); direct {
+				anyMasked = true
+			}
+		}
+	})
+
+	outputs := make([]bool, n)
+	if !anyMasked {
+		return outputs
+	}
+	for i := range outputs {
+		if len(plan.Output) != n {
+			outputs[i] = true
+			continue
+		}
+		direct, viaOpaque := readsMasked(plan.Output[i])
+		outputs[i] = direct || viaOpaque
+	}
+	return outputs
+}
+
+// columnMasked reports whether column of table, both identifiers as
+// returned by parseIdentifier, is masked. Tools that rename a column in
+// their result, such as value_counts, use it instead of maskedColumns.
+func (s *PostgresServer) columnMasked(table, column string) bool {
+	for _, m := range s.opts.MaskColumns {
+		if m.column == strings.ToLower(column) && (m.table == "" || m.table == strings.ToLower(table)) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryColumns returns the names of the columns query returns, without
+// reading any rows.
+func queryColumns(ctx context.Context, tx *sql.Tx, query string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT * FROM (%s) q LIMIT 0", query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// maskedSelectList returns the select list reading columns from a subquery
+// aliased q, with the non-NULL values of the masked ones replaced, for tools
+// that let Postgres encode the rows.
+func maskedSelectList(columns []string, masked map[string]bool) string {
+	items := make([]string, len(columns))
+	for i, column := range columns {
+		quoted := pgx.Identifier{column}.Sanitize()
+		if masked[column] {
+			items[i] = fmt.Sprintf("CASE WHEN q.%[1]s IS NULL THEN NULL ELSE '%[2]s' END AS %[1]s", quoted, maskToken)
+		} else {
+			items[i] = "q." + quoted
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// maskRow replaces the non-NULL values of the masked columns of row.
+func maskRow(row map[string]interface{}, masked map[string]bool) {
+	for column := range masked {
+		if row[column] != nil {
+			row[column] = maskToken
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestColumnMasked(t *testing.T) {
+	s := &PostgresServer{opts: ServerOptions{MaskColumns: parseColumnMasks("SSN, users.Email")}}
+	tests := []struct {
+		table, column string
+		want          bool
+	}{
+		{"people", "ssn", true},
+		{"people", "SSN", true},
+		{"users", "email", true},
+		{"people", "email", false},
+		{"users", "name", false},
+	}
+	for _, tt := range tests {
+		if got := s.columnMasked(tt.table, tt.column); got != tt.want {
+			t.Errorf("columnMasked(%q, %q) = %v, want %v", tt.table, tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestMaskedSelectList(t *testing.T) {
+	got := maskedSelectList([]string{"id", "Email"}, map[string]bool{"Email": true})
+	want := `q."id", CASE WHEN q."Email" IS NULL THEN NULL ELSE '***' END AS "Email"`
+	if got != want {
+		t.Errorf("maskedSelectList() = %q, want %q", got, want)
+	}
+}
+
+func TestPlanMaskedOutputs(t *testing.T) {
+	s := &PostgresServer{opts: ServerOptions{MaskColumns: parseColumnMasks("people.ssn")}}
+	scan := planNode{RelationName: "people", Alias: "p", Output: []string{"p.id", "p.ssn"}}
+
+	tests := []struct {
+		name string
+		plan planNode
+		n    int
+		want []bool
+	}{
+		{"renamed", planNode{RelationName: "people", Alias: "p", Output: []string{"p.id", "p.ssn"}}, 2, []bool{false, true}},
+		{"expression", planNode{Output: []string{"(p.ssn || ''::text)", "p.id"}, Plans: []planNode{scan}}, 2, []bool{true, false}},
+		{"aggregate", planNode{Output: []string{"max(p.ssn)", "count(*)"}, Plans: []planNode{scan}}, 2, []bool{true, false}},
+		{"through a subquery", planNode{Alias: "sub", Output: []string{"sub.x"}, Plans: []planNode{scan}}, 1, []bool{true}},
+		{"union", planNode{Plans: []planNode{scan, {RelationName: "other", Alias: "o", Output: []string{"o.a", "o.b"}}}}, 2, []bool{true, true}},
+		{"other table", planNode{RelationName: "accounts", Alias: "a", Output: []string{"a.ssn"}}, 1, []bool{false}},
+	}
+	for _, tt := range tests {
+		if got := s.planMaskedOutputs(tt.plan, tt.n); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: planMaskedOutputs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMaskingAppliesToEveryTool(t *testing.T) {
+	s := newTestServer(t, ServerOptions{MaskColumns: parseColumnMasks("people.email")})
+	schema := testSchema(t, s)
+	people := schema + ".people"
+	mustExec(t, s,
+		"CREATE TABLE "+people+" (id int PRIMARY KEY, email text, name text)",
+		"INSERT INTO "+people+" VALUES (1, 'alice@example.com', 'Alice'), (2, 'bob@example.com', 'Bob'), (3, NULL, 'Carol')",
+	)
+
+	tests := []struct {
+		tool    string
+		handler server.ToolHandlerFunc
+		args    map[string]interface{}
+	}{
+		{"postgres_query", s.ExecuteQuery, map[string]interface{}{"query": "SELECT id, email FROM " + people}},
+		{"postgres_query stream", s.ExecuteQuery, map[string]interface{}{"query": "SELECT id, email FROM " + people, "stream": true}},
+		{"postgres_query_params", s.ExecuteQueryParams, map[string]interface{}{"query": "SELECT id, email FROM " + people + " WHERE id <= $1", "params": []interface{}{2}}},
+		{"sample_rows", s.SampleRows, map[string]interface{}{"schema": schema, "table": "people"}},
+		{"iterate_table", s.IterateTable, map[string]interface{}{"table": people, "key_column": "id"}},
+		{"changes_since", s.ChangesSince, map[string]interface{}{"table": people, "cursor_column": "id", "since": 0}},
+		{"value_counts", s.ValueCounts, map[string]interface{}{"table": people, "column": "email"}},
+		{"column_stats", s.ColumnStats, map[string]interface{}{"schema": schema, "table": "people", "column": "email"}},
+		{"query_scalar", s.QueryScalar, map[string]interface{}{"query": "SELECT email FROM " + people + " WHERE id = 1"}},
+		{"query_json", s.QueryJSON, map[string]interface{}{"query": "SELECT id, email FROM " + people + " ORDER BY id"}},
+		{"diff_results", s.DiffResults, map[string]interface{}{
+			"query_a": "SELECT email FROM " + people,
+			"query_b": "SELECT email FROM " + people + " WHERE id = 1",
+		}},
+	}
+	for _, tt := range tests {
+		text, isError := callTool(t, tt.handler, tt.args)
+		if isError {
+			t.Errorf("%s failed: %s", tt.tool, text)
+			continue
+		}
+		if strings.Contains(text, "@example.com") || !strings.Contains(text, maskToken) {
+			t.Errorf("%s = %s, want the emails masked", tt.tool, text)
+		}
+	}
+
+	// Renaming or transforming a masked column does not unmask it.
+	for _, query := range []string{
+		"SELECT email AS contact FROM " + people,
+		"SELECT email || '' AS e, upper(email) FROM " + people,
+		"SELECT x FROM (SELECT email AS x FROM " + people + ") sub",
+		"WITH c AS MATERIALIZED (SELECT email FROM " + people + ") SELECT email AS y FROM c",
+		"SELECT max(email) FROM " + people,
+	} {
+		text, isError := callTool(t, s.ExecuteQuery, map[string]interface{}{"query": query})
+		if isError || strings.Contains(text, "@example.com") {
+			t.Errorf("postgres_query(%q) = %s, want the emails masked", query, text)
+		}
+	}
+
+	// query_json keeps the column order and NULLs.
+	text, _ := callTool(t, s.QueryJSON, map[string]interface{}{"query": "SELECT id, email FROM " + people + " WHERE id = 3"})
+	if text != `[{"id":3,"email":null}]` {
+		t.Errorf("query_json = %s, want the NULL email kept", text)
+	}
+
+	// Unmasked columns of the same tools are returned as they are.
+	if text, _ := callTool(t, s.SampleRows, map[string]interface{}{"schema": schema, "table": "people"}); !strings.Contains(text, "Alice") {
+		t.Errorf("sample_rows = %s, want the names unmasked", text)
+	}
+
+	// A masked column would leak through the returned cursor.
+	for _, tt := range []struct {
+		handler server.ToolHandlerFunc
+		args    map[string]interface{}
+	}{
+		{s.IterateTable, map[string]interface{}{"table": people, "key_column": "email"}},
+		{s.ChangesSince, map[string]interface{}{"table": people, "cursor_column": "email", "since": ""}},
+	} {
+		if text, isError := callTool(t, tt.handler, tt.args); !isError || !strings.Contains(text, "masked") {
+			t.Errorf("got %q, want the masked cursor column rejected", text)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxLoggedQueryLength caps how much of a query is written to the log.
+const maxLoggedQueryLength = 200
+
+// rowCountKey is the context key under which loggingMiddleware collects the
+// number of rows a tool returned.
+type rowCountKey struct{}
+
+// recordRowCount reports the number of rows a tool call returned, for the
+// call's log entry.
+func recordRowCount(ctx context.Context, n int) {
+	if p, ok := ctx.Value(rowCountKey{}).(*int); ok {
+		*p = n
+	}
+}
+
+// loggingMiddleware logs every tool call at debug level with its duration
+// and, where the tool reports it, the number of rows returned.
+func loggingMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rows := -1
+		ctx = context.WithValue(ctx, rowCountKey{}, &rows)
+
+		start := time.Now()
+		result, err := next(ctx, req)
+
+		attrs := []any{
+			"tool", req.Params.Name,
+			"duration_ms", time.Since(start).Milliseconds(),
+		}
+		if query := req.GetString("query", ""); query != "" {
+			attrs = append(attrs, "query", truncateForLog(query, maxLoggedQueryLength))
+		}
+		if rows >= 0 {
+			attrs = append(attrs, "rows", rows)
+		}
+		switch {
+		case err != nil:
+			attrs = append(attrs, "error", err.Error())
+		case result != nil && result.IsError:
+			attrs = append(attrs, "failed", true)
+		}
+		slog.DebugContext(ctx, "tool call", attrs...)
+		return result, err
+	}
+}
+
+// timeoutMiddleware bounds every tool call by DB_QUERY_TIMEOUT.
+// The deadline cancels the client-side wait; the matching statement_timeout
+// session setting (see DatabaseConfig.StatementTimeout) stops the work on
+// the server as well.
+func (s *PostgresServer) timeoutMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.opts.QueryTimeout <= 0 {
+			return next(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, s.opts.QueryTimeout)
+		defer cancel()
+
+		result, err := next(ctx, req)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return mcp.NewToolResultError(fmt.Sprintf("%s timed out: it did not finish within the %s query timeout (DB_QUERY_TIMEOUT)",
+				req.Params.Name, s.opts.QueryTimeout)), nil
+		}
+		return result, err
+	}
+}
+
+// concurrencyMiddleware caps the tool calls running at once at
+// DB_MAX_CONCURRENT_QUERIES. A call waits for a free slot until its context
+// is done, so it runs inside timeoutMiddleware and the wait counts towards
+// DB_QUERY_TIMEOUT.
+func (s *PostgresServer) concurrencyMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.querySlots == nil {
+			return next(ctx, req)
+		}
+
+		select {
+		case s.querySlots <- struct{}{}:
+		case <-ctx.Done():
+			return mcp.NewToolResultError(fmt.Sprintf("%s was not run: all %d query slots stayed busy (DB_MAX_CONCURRENT_QUERIES)",
+				req.Params.Name, cap(s.querySlots))), nil
+		}
+		defer func() { <-s.querySlots }()
+
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func (s *PostgresServer) ReplicationStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var inRecovery bool
+	err := s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect node role: %w", err)
+	}
+
+	if !inRecovery {
+		rows, err := s.queryContext(ctx, `
+            SELECT application_name,
+                   client_addr::text AS client_addr,
+                   state,
+                   sync_state,
+                   pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn)::bigint AS lag_bytes
+            FROM pg_stat_replication
+            ORDER BY application_name
+        `)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pg_stat_replication: %w", err)
+		}
+		defer rows.Close()
+
+		replicas, err := scanRows(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		response, _ := json.Marshal(map[string]interface{}{
+			"role":     "primary",
+			"replicas": replicas.Rows,
+		})
+		return mcp.NewToolResultText(string(response)), nil
+	}
+
+	var receiveLSN, replayLSN *string
+	var lagBytes *int64
+	var lagSeconds *float64
+	err = s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT pg_last_wal_receive_lsn()::text,
+                   pg_last_wal_replay_lsn()::text,
+                   pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn())::bigint,
+                   extract(epoch FROM now() - pg_last_xact_replay_timestamp())::float8
+        `).Scan(&receiveLSN, &replayLSN, &lagBytes, &lagSeconds)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replica status: %w", err)
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"role":               "replica",
+		"last_receive_lsn":   receiveLSN,
+		"last_replay_lsn":    replayLSN,
+		"replay_lag_bytes":   lagBytes,
+		"replay_lag_seconds": lagSeconds,
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) ListActiveQueries(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rows, err := s.queryContext(ctx, `
+        SELECT pid, usename, state, query, query_start
+        FROM pg_stat_activity
+        WHERE state <> 'idle'
+          AND pid <> pg_backend_pid()
+        ORDER BY query_start
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pg_stat_activity: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result.Rows)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) ConnectionInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var database, user, version, serverVersion, searchPath string
+	var serverVersionNum int
+	err := s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT current_database(),
+                   current_user,
+                   version(),
+                   current_setting('server_version'),
+                   current_setting('server_version_num')::int,
+                   current_setting('search_path')
+        `).Scan(&database, &user, &version, &serverVersion, &serverVersionNum, &searchPath)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection info: %w", err)
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"database":           database,
+		"user":               user,
+		"version":            version,
+		"server_version":     serverVersion,
+		"server_version_num": serverVersionNum,
+		"search_path":        searchPath,
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// commonSettings are the settings get_setting returns when no name is given.
+var commonSettings = []string{
+	"statement_timeout",
+	"work_mem",
+	"max_connections",
+	"timezone",
+	"default_transaction_read_only",
+}
+
+func (s *PostgresServer) GetSetting(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	names := commonSettings
+	if name := req.GetString("name", ""); name != "" {
+		names = []string{name}
+	}
+
+	settings := make(map[string]string, len(names))
+	for _, name := range names {
+		var value string
+		err := s.withConnRetry(ctx, func() error {
+			return s.db.QueryRowContext(ctx, "SELECT current_setting($1)", name).Scan(&value)
+		})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to read setting %q: %s", name, s.errorText(err))), nil
+		}
+		settings[name] = value
+	}
+
+	response, _ := json.Marshal(settings)
+	return mcp.NewToolResultText(string(response)), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+)
+
+// newMockCatalogServer connects to the test database with schema searched
+// before pg_catalog, so that views and functions created there stand in
+// for the system ones of the same name.
+func newMockCatalogServer(t *testing.T, schema string) *PostgresServer {
+	t.Helper()
+	return newTestServerWithParams(t, ServerOptions{}, "search_path="+schema+",pg_catalog")
+}
+
+func TestReplicationStatusPrimary(t *testing.T) {
+	setup := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, setup)
+	mustExec(t, setup,
+		"CREATE FUNCTION "+schema+".pg_is_in_recovery() RETURNS boolean LANGUAGE sql AS 'SELECT false'",
+		`CREATE VIEW `+schema+`.pg_stat_replication AS
+			SELECT * FROM (VALUES
+				('replica_b', '10.0.0.2'::inet, 'streaming', 'async', '0/0'::pg_lsn),
+				('replica_a', '10.0.0.1'::inet, 'catchup', 'sync', pg_current_wal_lsn())
+			) AS r(application_name, client_addr, state, sync_state, replay_lsn)`,
+	)
+	s := newMockCatalogServer(t, schema)
+
+	var got struct {
+		Role     string                   `json:"role"`
+		Replicas []map[string]interface{} `json:"replicas"`
+	}
+	callToolJSON(t, s.ReplicationStatus, nil, &got)
+
+	if got.Role != "primary" || len(got.Replicas) != 2 {
+		t.Fatalf("got %+v, want a primary with two replicas", got)
+	}
+	a, b := got.Replicas[0], got.Replicas[1]
+	if a["application_name"] != "replica_a" || a["client_addr"] != "10.0.0.1/32" || a["state"] != "catchup" || a["sync_state"] != "sync" {
+		t.Errorf("first replica = %v, want replica_a", a)
+	}
+	if lag, ok := b["lag_bytes"].(float64); !ok || lag <= 0 {
+		t.Errorf("replica_b lag_bytes = %v, want the distance from 0/0 to the current LSN", b["lag_bytes"])
+	}
+}
+
+func TestReplicationStatusReplica(t *testing.T) {
+	setup := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, setup)
+	mustExec(t, setup,
+		"CREATE FUNCTION "+schema+".pg_is_in_recovery() RETURNS boolean LANGUAGE sql AS 'SELECT true'",
+		"CREATE FUNCTION "+schema+".pg_last_wal_receive_lsn() RETURNS pg_lsn LANGUAGE sql AS $$SELECT '0/3000100'::pg_lsn$$",
+		"CREATE FUNCTION "+schema+".pg_last_wal_replay_lsn() RETURNS pg_lsn LANGUAGE sql AS $$SELECT '0/3000000'::pg_lsn$$",
+		"CREATE FUNCTION "+schema+".pg_last_xact_replay_timestamp() RETURNS timestamptz LANGUAGE sql AS $$SELECT now() - interval '2 seconds'$$",
+	)
+	s := newMockCatalogServer(t, schema)
+
+	var got struct {
+		Role       string  `json:"role"`
+		ReceiveLSN string  `json:"last_receive_lsn"`
+		ReplayLSN  string  `json:"last_replay_lsn"`
+		LagBytes   int64   `json:"replay_lag_bytes"`
+		LagSeconds float64 `json:"replay_lag_seconds"`
+	}
+	callToolJSON(t, s.ReplicationStatus, nil, &got)
+
+	if got.Role != "replica" || got.ReceiveLSN != "0/3000100" || got.ReplayLSN != "0/3000000" {
+		t.Errorf("got %+v, want a replica at 0/3000100 received and 0/3000000 replayed", got)
+	}
+	if got.LagBytes != 256 {
+		t.Errorf("replay_lag_bytes = %d, want 256", got.LagBytes)
+	}
+	if got.LagSeconds != 2 {
+		t.Errorf("replay_lag_seconds = %v, want 2", got.LagSeconds)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// truncationMarker is the last line of stream output when rows past the cap
+// were left unread.
+type truncationMarker struct {
+	Truncated  bool `json:"truncated"`
+	MaxRows    int  `json:"max_rows"`
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+// encodeRows writes rows to out as newline-delimited JSON while reading them,
+// for postgres_query with stream=true. Unlike the other formats, the rows are
+// never collected as values first: each is encoded as soon as it has been
+// scanned. At most maxRows rows are written (all of them when zero); when
+// more are left unread a truncationMarker line follows them, with the next
+// page's offset when paged. It returns the number of rows written.
+func (s *PostgresServer) encodeRows(rows *sql.Rows, out io.Writer, maxRows int, paged bool, offset int, masked map[string]bool) (int, error) {
+	// Columns past --max-columns are dropped, as in the other formats.
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get columns: %w", err)
+	}
+	var omitted []string
+	if max := s.opts.MaxColumns; max > 0 && len(columns) > max {
+		omitted = columns[max:]
+	}
+
+	enc := json.NewEncoder(out)
+	count := 0
+	_, more, err := forEachRow(rows, maxRows, masked, func(row map[string]interface{}) error {
+		for _, column := range omitted {
+			delete(row, column)
+		}
+		count++
+		return enc.Encode(row)
+	})
+	if err != nil {
+		return count, err
+	}
+	if more {
+		marker := truncationMarker{Truncated: true, MaxRows: maxRows}
+		if paged {
+			nextOffset := offset + count
+			marker.NextOffset = &nextOffset
+		}
+		if err := enc.Encode(marker); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// streamChunkSize is roughly how much ndjson a rowStream collects before
+// sending it to the client.
+const streamChunkSize = 64 << 10
+
+// rowStream sends what is written to it to the client as
+// notifications/progress messages for the request's progress token, in
+// chunks of whole lines. Progress counts the lines sent so far.
+type rowStream struct {
+	ctx   context.Context
+	srv   *server.MCPServer
+	token mcp.ProgressToken
+	buf   bytes.Buffer
+	lines int
+}
+
+// newRowStream returns a rowStream for the request in ctx, or nil when the
+// client did not ask for progress notifications or cannot receive them.
+func newRowStream(ctx context.Context, req mcp.CallToolRequest) *rowStream {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil || server.ClientSessionFromContext(ctx) == nil ||
+		req.Params.Meta == nil || req.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	return &rowStream{ctx: ctx, srv: srv, token: req.Params.Meta.ProgressToken}
+}
+
+// Write collects p, which json.Encoder always passes as one whole line,
+// and sends the collected lines once there are enough of them.
+func (w *rowStream) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	w.lines++
+	if w.buf.Len() >= streamChunkSize {
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends the lines collected so far. The session's notification
+// channel is buffered and never blocks, so a full channel is retried until
+// the client has caught up instead of dropping rows.
+func (w *rowStream) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	params := map[string]any{
+		"progressToken": w.token,
+		"progress":      w.lines,
+		"message":       w.buf.String(),
+	}
+	for {
+		err := w.srv.SendNotificationToClient(w.ctx, "notifications/progress", params)
+		if !errors.Is(err, server.ErrNotificationChannelBlocked) {
+			if err != nil {
+				return err
+			}
+			break
+		}
+		select {
+		case <-w.ctx.Done():
+			return w.ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	w.buf.Reset()
+	return nil
+}
+
+// formatCSV renders a result as CSV: a header row with the column names,
+// then one record per row. NULL becomes an empty field; arrays and JSON
+// documents are written as JSON text.
+func formatCSV(result *QueryResult) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+
+	w.Write(result.Columns)
+	record := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i, column := range result.Columns {
+			record[i] = cellText(row[column])
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// defaultMarkdownCellWidth is the default max_cell_width of the markdown
+// format.
+const defaultMarkdownCellWidth = 80
+
+// markdownEscaper keeps values from breaking the table layout.
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "")
+
+// formatMarkdown renders a result as a GitHub-flavored Markdown table.
+// Values longer than maxWidth characters are cut off with an ellipsis;
+// zero keeps them whole. NULL becomes an empty cell.
+func formatMarkdown(result *QueryResult, maxWidth int) string {
+	if len(result.Columns) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for _, cell := range cells {
+			b.WriteString(" ")
+			b.WriteString(markdownCell(cell, maxWidth))
+			b.WriteString(" |")
+		}
+		b.WriteString("\n")
+	}
+
+	writeRow(result.Columns)
+	b.WriteString(strings.Repeat("| --- ", len(result.Columns)) + "|\n")
+	cells := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i, column := range result.Columns {
+			cells[i] = cellText(row[column])
+		}
+		writeRow(cells)
+	}
+	return b.String()
+}
+
+// markdownCell truncates text to maxWidth characters and escapes it for a
+// table cell.
+func markdownCell(text string, maxWidth int) string {
+	if maxWidth > 0 && utf8.RuneCountInString(text) > maxWidth {
+		text = string([]rune(text)[:max(maxWidth-1, 0)]) + "…"
+	}
+	return markdownEscaper.Replace(text)
+}
+
+// cellText renders a normalized column value as plain text for tabular
+// output formats.
+func cellText(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	case json.RawMessage:
+		return string(v)
+	default:
+		text, _ := json.Marshal(v)
+		return string(text)
+	}
+}
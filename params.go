@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxExactFloat is the largest integer a float64 represents exactly.
+const maxExactFloat = 1 << 53
+
+func (s *PostgresServer) ExecuteQueryParams(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'query'"), nil
+	}
+
+	var params []interface{}
+	if raw, ok := req.GetArguments()["params"]; ok && raw != nil {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return mcp.NewToolResultError("Parameter 'params' must be a JSON array"), nil
+		}
+		params = list
+	}
+	args := make([]interface{}, len(params))
+	for i, p := range params {
+		args[i] = bindValue(p)
+	}
+
+	if err := s.isSafeQuery(query); err != nil {
+		return nil, fmt.Errorf("unsafe query: %w", err)
+	}
+	if err := s.checkTableAccess(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.beginReadOnlyTx(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	defer tx.Rollback()
+
+	// Cap the query in SQL, as postgres_query does, so the server stops at
+	// the first row past DB_MAX_ROWS.
+	executed := query
+	if s.opts.MaxRows > 0 {
+		executed = withDefaultLimit(query, s.opts.MaxRows)
+	}
+	rows, err := tx.QueryContext(ctx, executed, args...)
+	if err != nil {
+		return s.queryFailed(err, query, executed), nil
+	}
+	defer rows.Close()
+
+	response, more, err := s.scanQueryRows(ctx, rows, s.opts.MaxRows, query, args...)
+	if err != nil {
+		return s.queryFailed(err, query, executed), nil
+	}
+	if more {
+		response.Truncated = true
+		response.MaxRows = s.opts.MaxRows
+	}
+	response.limitColumns(s.opts.MaxColumns)
+	recordRowCount(ctx, response.Count)
+
+	responseJSON, _ := json.Marshal(response)
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// bindValue converts a decoded JSON parameter into a driver value. Whole
+// numbers are bound as integers so they compare cleanly with integer
+// columns; objects and arrays are bound as their JSON text, which suits
+// json and jsonb parameters.
+func bindValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= maxExactFloat {
+			return int64(v)
+		}
+		return v
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		// string, bool and nil bind as they are.
+		return v
+	}
+}
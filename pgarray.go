@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parseArray decodes the text form of a Postgres array, such as
+// {1,2,NULL} or {{"a b",c},{d,e}}, into nested []interface{} values.
+// Elements are converted according to elemType, the element's database type
+// name; NULL elements become nil.
+func parseArray(text, elemType string) (interface{}, error) {
+	// Arrays with non-default bounds are prefixed by their dimensions, e.g.
+	// [0:1]={1,2}.
+	if strings.HasPrefix(text, "[") {
+		eq := strings.IndexByte(text, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("malformed array %q", text)
+		}
+		text = text[eq+1:]
+	}
+
+	p := arrayParser{text: text, elemType: elemType}
+	value, err := p.parseArray()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.text) {
+		return nil, fmt.Errorf("malformed array %q", text)
+	}
+	return value, nil
+}
+
+type arrayParser struct {
+	text     string
+	pos      int
+	elemType string
+}
+
+func (p *arrayParser) parseArray() ([]interface{}, error) {
+	if p.pos >= len(p.text) || p.text[p.pos] != '{' {
+		return nil, fmt.Errorf("malformed array %q", p.text)
+	}
+	p.pos++
+
+	elems := make([]interface{}, 0)
+	if p.pos < len(p.text) && p.text[p.pos] == '}' {
+		p.pos++
+		return elems, nil
+	}
+	for {
+		if p.pos >= len(p.text) {
+			return nil, fmt.Errorf("malformed array %q", p.text)
+		}
+
+		var elem interface{}
+		switch p.text[p.pos] {
+		case '{':
+			nested, err := p.parseArray()
+			if err != nil {
+				return nil, err
+			}
+			elem = nested
+		case '"':
+			s, err := p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+			elem = arrayElement(s, p.elemType)
+		default:
+			end := p.pos
+			for end < len(p.text) && p.text[end] != ',' && p.text[end] != '}' {
+				end++
+			}
+			s := strings.TrimSpace(p.text[p.pos:end])
+			p.pos = end
+			if strings.EqualFold(s, "NULL") {
+				elem = nil
+			} else {
+				elem = arrayElement(s, p.elemType)
+			}
+		}
+		elems = append(elems, elem)
+
+		if p.pos >= len(p.text) {
+			return nil, fmt.Errorf("malformed array %q", p.text)
+		}
+		switch p.text[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return elems, nil
+		default:
+			return nil, fmt.Errorf("malformed array %q", p.text)
+		}
+	}
+}
+
+// parseQuoted reads a double-quoted element, in which backslash escapes the
+// next character.
+func (p *arrayParser) parseQuoted() (string, error) {
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.text); p.pos++ {
+		switch c := p.text[p.pos]; c {
+		case '\\':
+			p.pos++
+			if p.pos < len(p.text) {
+				b.WriteByte(p.text[p.pos])
+			}
+		case '"':
+			p.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("malformed array %q", p.text)
+}
+
+// arrayElement converts the text of a non-NULL array element.
+func arrayElement(s, elemType string) interface{} {
+	switch elemType {
+	case "INT2", "INT4", "INT8", "FLOAT4", "FLOAT8", "OID":
+		if json.Valid([]byte(s)) {
+			return json.Number(s)
+		}
+		return s
+	case "BOOL":
+		return s == "t"
+	default:
+		return normalizeValue([]byte(s), elemType)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func (s *PostgresServer) setupMCPPrompts(mcpServer *server.MCPServer) {
+	generateSQLPrompt := mcp.NewPrompt(
+		"generate_sql",
+		mcp.WithPromptDescription("Write a read-only SQL query that answers a question, grounded in the tables of the database"),
+		mcp.WithArgument("question",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("Question to answer, in plain language"),
+		),
+	)
+
+	mcpServer.AddPrompt(generateSQLPrompt, s.GenerateSQLPrompt)
+}
+
+func (s *PostgresServer) GenerateSQLPrompt(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	question := strings.TrimSpace(req.Params.Arguments["question"])
+	if question == "" {
+		return nil, fmt.Errorf("missing required argument 'question'")
+	}
+
+	schemaInfo, err := s.getSchemaInfo(ctx, defaultSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema: %w", err)
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Write a single PostgreSQL query that answers this question:\n\n%s\n\n", question)
+	text.WriteString("Rules:\n")
+	text.WriteString("- Only SELECT (or WITH ... SELECT) is allowed; the query runs in a read-only transaction.\n")
+	text.WriteString("- Use only the tables and columns listed below.\n")
+	text.WriteString("- Send exactly one statement, then run it with the postgres_query tool.\n\n")
+	fmt.Fprintf(&text, "Tables in schema %s, as table(column type, ...):\n", defaultSchema)
+	text.WriteString(compactSchema(schemaInfo))
+
+	return mcp.NewGetPromptResult(
+		"Generate a read-only SQL query",
+		[]mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text.String())),
+		},
+	), nil
+}
+
+// compactSchema renders schema information one table per line, e.g.
+// "users(id integer, name text)", which costs far fewer tokens than JSON.
+func compactSchema(schemaInfo map[string][]map[string]string) string {
+	tables := make([]string, 0, len(schemaInfo))
+	for table := range schemaInfo {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var b strings.Builder
+	for _, table := range tables {
+		columns := make([]string, 0, len(schemaInfo[table]))
+		for _, c := range schemaInfo[table] {
+			columns = append(columns, c["column"]+" "+c["type"])
+		}
+		fmt.Fprintf(&b, "%s(%s)\n", table, strings.Join(columns, ", "))
+	}
+	return b.String()
+}
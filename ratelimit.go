@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterIdleTimeout is how long a client's limiter is kept after its
+// last request.
+const rateLimiterIdleTimeout = 10 * time.Minute
+
+// clientLimiter is the token bucket of one client IP
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter hands out a token bucket per client IP.
+type ipRateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+func newIPRateLimiter(limit float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		limit:     rate.Limit(limit),
+		burst:     burst,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow reports whether ip may make another request now.
+func (l *ipRateLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > rateLimiterIdleTimeout {
+		for key, client := range l.clients {
+			if now.Sub(client.lastSeen) > rateLimiterIdleTimeout {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = now
+	return client.limiter.Allow()
+}
+
+// rateLimitMiddleware rejects requests with 429 once a client IP exceeds
+// its rate. trustedProxies is the number of reverse proxies in front of the
+// server that append to X-Forwarded-For; the client IP is the entry the
+// outermost of them appended (see clientIP). With none, it is taken from the
+// connection.
+func rateLimitMiddleware(limiter *ipRateLimiter, trustedProxies int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limiter.allow(clientIP(r, trustedProxies)) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the IP address a request came from. Each of the
+// trustedProxies proxies appends the address it received the request from
+// to X-Forwarded-For, so the client is the trustedProxies-th entry from the
+// right; anything left of it was sent by the client and may be forged. When
+// there are fewer entries, all of them were appended by trusted proxies and
+// the leftmost is used.
+func clientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		var entries []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, entry := range strings.Split(header, ",") {
+				entries = append(entries, strings.TrimSpace(entry))
+			}
+		}
+		if len(entries) > 0 {
+			if ip := entries[max(len(entries)-trustedProxies, 0)]; ip != "" {
+				return ip
+			}
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
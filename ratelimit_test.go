@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		forwarded      []string
+		trustedProxies int
+		want           string
+	}{
+		{"no proxy", []string{"203.0.113.7"}, 0, "10.0.0.1"},
+		{"one proxy", []string{"203.0.113.7"}, 1, "203.0.113.7"},
+		{"spoofed entry before the proxy's", []string{"198.51.100.1, 203.0.113.7"}, 1, "203.0.113.7"},
+		{"spoofed header before the proxy's", []string{"198.51.100.1", "203.0.113.7"}, 1, "203.0.113.7"},
+		{"two proxies", []string{"198.51.100.1, 203.0.113.7, 192.0.2.9"}, 2, "203.0.113.7"},
+		{"fewer entries than proxies", []string{"203.0.113.7"}, 2, "203.0.113.7"},
+		{"no header", nil, 1, "10.0.0.1"},
+		{"empty entry", []string{"198.51.100.1, "}, 1, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		r.RemoteAddr = "10.0.0.1:54321"
+		for _, value := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if got := clientIP(r, tt.trustedProxies); got != tt.want {
+			t.Errorf("%s: clientIP() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	handler := rateLimitMiddleware(newIPRateLimiter(1, 1), 1, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// A client rotating the leftmost entry still gets the proxy-appended
+	// address as its key, so it shares one bucket.
+	codes := make([]int, 0, 3)
+	for _, spoofed := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		r.Header.Set("X-Forwarded-For", spoofed+", 203.0.113.7")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("got status codes %v, want the second and third requests limited", codes)
+	}
+
+	// Another real client has its own bucket.
+	r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	r.Header.Set("X-Forwarded-For", "203.0.113.8")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("another client got %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// readOnlyTxOptions makes the driver open transactions with BEGIN READ ONLY.
+// Postgres then rejects any write the statement tries, including ones the
+// isSafeQuery checks cannot see, such as data-modifying functions or
+// SELECT ... INTO.
+var readOnlyTxOptions = &sql.TxOptions{ReadOnly: true}
+
+// beginReadOnlyTx starts a read-only transaction for running user SQL.
+// Callers must roll the transaction back when done.
+func (s *PostgresServer) beginReadOnlyTx(ctx context.Context) (*sql.Tx, error) {
+	var tx *sql.Tx
+	err := s.withConnRetry(ctx, func() error {
+		var err error
+		tx, err = s.db.BeginTx(ctx, readOnlyTxOptions)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %s", s.errorText(err))
+	}
+	return tx, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultSchemaCacheTTL is used when DB_SCHEMA_CACHE_TTL is not set.
+const defaultSchemaCacheTTL = 60 * time.Second
+
+// schemaCacheEntry is the cached table and column listing of one schema
+type schemaCacheEntry struct {
+	loadedAt time.Time
+	tables   map[string][]map[string]string
+}
+
+// getSchemaInfo returns the tables of schema with their columns and types.
+// The result is cached for SchemaCacheTTL, so that a burst of failing
+// queries does not read the catalog once per table every time.
+func (s *PostgresServer) getSchemaInfo(ctx context.Context, schema string) (map[string][]map[string]string, error) {
+	ttl := s.opts.SchemaCacheTTL
+	if ttl > 0 {
+		s.schemaCacheMu.Lock()
+		entry, ok := s.schemaCache[schema]
+		s.schemaCacheMu.Unlock()
+		if ok && time.Since(entry.loadedAt) < ttl {
+			return entry.tables, nil
+		}
+	}
+
+	tables, err := s.loadSchemaInfo(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 {
+		s.schemaCacheMu.Lock()
+		if s.schemaCache == nil {
+			s.schemaCache = make(map[string]schemaCacheEntry)
+		}
+		s.schemaCache[schema] = schemaCacheEntry{loadedAt: time.Now(), tables: tables}
+		s.schemaCacheMu.Unlock()
+	}
+	return tables, nil
+}
+
+// loadSchemaInfo reads the tables of schema and their columns from the
+// catalog.
+func (s *PostgresServer) loadSchemaInfo(ctx context.Context, schema string) (map[string][]map[string]string, error) {
+	schemaInfo := make(map[string][]map[string]string)
+
+	// Get all tables
+	tableRows, err := s.queryContext(ctx, listTablesQueries[s.opts.IntrospectionSource], schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer tableRows.Close()
+
+	var tables []string
+	for tableRows.Next() {
+		var t string
+		if err := tableRows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+
+	// Get columns for each table
+	for _, table := range tables {
+		columns, err := s.describeColumns(ctx, schema, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe table %s: %w", table, err)
+		}
+
+		var cols []map[string]string
+		for _, c := range columns {
+			cols = append(cols, map[string]string{"column": c.Column, "type": c.Type})
+		}
+		schemaInfo[table] = cols
+	}
+
+	return schemaInfo, nil
+}
+
+// invalidateSchemaCache drops the cached schema, or every cached schema when
+// schema is empty, and returns how many entries were dropped.
+func (s *PostgresServer) invalidateSchemaCache(schema string) int {
+	s.schemaCacheMu.Lock()
+	defer s.schemaCacheMu.Unlock()
+
+	if schema == "" {
+		n := len(s.schemaCache)
+		s.schemaCache = nil
+		return n
+	}
+	if _, ok := s.schemaCache[schema]; !ok {
+		return 0
+	}
+	delete(s.schemaCache, schema)
+	return 1
+}
+
+func (s *PostgresServer) RefreshSchemaCache(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schema := req.GetString("schema", "")
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"invalidated": s.invalidateSchemaCache(schema),
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// SessionSettings describes the execution environment queries run in
+type SessionSettings struct {
+	StatementTimeout string `json:"statement_timeout"`
+	SearchPath       string `json:"search_path"`
+	TimeZone         string `json:"timezone"`
+	// TransactionReadOnly is read inside the read-only transaction user
+	// queries run in, so it shows what actually applies to them.
+	// DefaultTransactionReadOnly is only the server's default for other
+	// transactions, and is usually off.
+	TransactionReadOnly        string `json:"transaction_read_only"`
+	DefaultTransactionReadOnly string `json:"default_transaction_read_only"`
+	TransactionIsolation       string `json:"transaction_isolation"`
+}
+
+func (s *PostgresServer) SessionSettings(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tx, err := s.beginReadOnlyTx(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	defer tx.Rollback()
+
+	var settings SessionSettings
+	err = tx.QueryRowContext(ctx, `
+        SELECT current_setting('statement_timeout'),
+               current_setting('search_path'),
+               current_setting('TimeZone'),
+               current_setting('transaction_read_only'),
+               current_setting('default_transaction_read_only'),
+               current_setting('transaction_isolation')
+    `).Scan(
+		&settings.StatementTimeout,
+		&settings.SearchPath,
+		&settings.TimeZone,
+		&settings.TransactionReadOnly,
+		&settings.DefaultTransactionReadOnly,
+		&settings.TransactionIsolation,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session settings: %w", err)
+	}
+
+	response, _ := json.Marshal(settings)
+	return mcp.NewToolResultText(string(response)), nil
+}
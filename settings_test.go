@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSessionSettings(t *testing.T) {
+	url := os.Getenv(testDatabaseEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+	s, err := NewPostgresServer(DatabaseConfig{URL: url, TimeZone: "Asia/Tokyo"}, ServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var got SessionSettings
+	callToolJSON(t, s.SessionSettings, nil, &got)
+	if got.TimeZone != "Asia/Tokyo" {
+		t.Errorf("timezone = %q, want Asia/Tokyo", got.TimeZone)
+	}
+	if got.TransactionReadOnly != "on" {
+		t.Errorf("transaction_read_only = %q, want on", got.TransactionReadOnly)
+	}
+	if got.TransactionIsolation == "" {
+		t.Error("transaction_isolation is empty")
+	}
+}
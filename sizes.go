@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// TableSize holds the on-disk size of a table
+type TableSize struct {
+	Table string `json:"table"`
+	// TotalBytes includes indexes and TOAST data; TableBytes is the main
+	// heap only.
+	TotalBytes    int64  `json:"total_bytes"`
+	TotalPretty   string `json:"total_pretty"`
+	TableBytes    int64  `json:"table_bytes"`
+	TablePretty   string `json:"table_pretty"`
+	IndexesBytes  int64  `json:"indexes_bytes"`
+	IndexesPretty string `json:"indexes_pretty"`
+}
+
+func (s *PostgresServer) TableSize(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	schema, table, err := resolveTableName(req.GetString("schema", defaultSchema), table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var size TableSize
+	err = s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT format('%I.%I', n.nspname, c.relname),
+                   pg_total_relation_size(c.oid), pg_size_pretty(pg_total_relation_size(c.oid)),
+                   pg_relation_size(c.oid), pg_size_pretty(pg_relation_size(c.oid)),
+                   pg_indexes_size(c.oid), pg_size_pretty(pg_indexes_size(c.oid))
+            FROM pg_catalog.pg_class c
+            JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+            WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN ('r', 'p', 'm')
+        `, schema, table).Scan(&size.Table,
+			&size.TotalBytes, &size.TotalPretty,
+			&size.TableBytes, &size.TablePretty,
+			&size.IndexesBytes, &size.IndexesPretty)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return mcp.NewToolResultError(fmt.Sprintf("Table %s.%s does not exist", schema, table)), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table size: %w", err)
+	}
+
+	response, _ := json.Marshal(size)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) DatabaseSize(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var name, pretty string
+	var bytes int64
+	err := s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT current_database(),
+                   pg_database_size(current_database()),
+                   pg_size_pretty(pg_database_size(current_database()))
+        `).Scan(&name, &bytes, &pretty)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"database": name,
+		"bytes":    bytes,
+		"pretty":   pretty,
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) EstimateRowCount(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	schema, table, err := resolveTableName(req.GetString("schema", defaultSchema), table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var reltuples float64
+	var lastAnalyzed sql.NullTime
+	err = s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT c.reltuples, greatest(st.last_analyze, st.last_autoanalyze)
+            FROM pg_catalog.pg_class c
+            JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+            LEFT JOIN pg_catalog.pg_stat_user_tables st ON st.relid = c.oid
+            WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN ('r', 'p', 'm')
+        `, schema, table).Scan(&reltuples, &lastAnalyzed)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return mcp.NewToolResultError(fmt.Sprintf("Table %s.%s does not exist", schema, table)), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate row count: %w", err)
+	}
+
+	result := map[string]interface{}{
+		"table":          schema + "." + table,
+		"estimated_rows": int64(reltuples),
+		"note":           "Planner estimate from pg_class.reltuples, refreshed by ANALYZE, VACUUM and autovacuum; it is not an exact count.",
+	}
+	// Since Postgres 14, -1 means the table has never been analyzed.
+	if reltuples < 0 {
+		result["estimated_rows"] = nil
+		result["note"] = "The table has never been analyzed, so there is no estimate yet; run ANALYZE or use count(*)."
+	}
+	if lastAnalyzed.Valid {
+		result["last_analyzed"] = lastAnalyzed.Time.Format(time.RFC3339)
+	}
+
+	response, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(response)), nil
+}
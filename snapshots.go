@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// rowCountSnapshot records estimated row counts per table at a point in time
+type rowCountSnapshot struct {
+	takenAt time.Time
+	counts  map[string]int64
+}
+
+// TableRowCount is one table's entry in a row_count_snapshot response
+type TableRowCount struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	Delta *int64 `json:"delta,omitempty"`
+}
+
+func (s *PostgresServer) RowCountSnapshot(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rows, err := s.queryContext(ctx, `
+        SELECT schemaname || '.' || relname, n_live_tup
+        FROM pg_stat_user_tables
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read row counts: %w", err)
+	}
+	defer rows.Close()
+
+	current := rowCountSnapshot{takenAt: time.Now().UTC(), counts: make(map[string]int64)}
+	for rows.Next() {
+		var table string
+		var count int64
+		if err := rows.Scan(&table, &count); err != nil {
+			return nil, err
+		}
+		current.counts[table] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read row counts: %w", err)
+	}
+
+	s.snapshotMu.Lock()
+	var previous *rowCountSnapshot
+	if n := len(s.snapshots); n > 0 {
+		previous = &s.snapshots[n-1]
+	}
+	s.snapshots = append(s.snapshots, current)
+	if retention := s.opts.SnapshotRetention; retention > 0 && len(s.snapshots) > retention {
+		s.snapshots = s.snapshots[len(s.snapshots)-retention:]
+	}
+	s.snapshotMu.Unlock()
+
+	tables := make([]TableRowCount, 0, len(current.counts))
+	for table, count := range current.counts {
+		entry := TableRowCount{Table: table, Rows: count}
+		if previous != nil {
+			delta := count - previous.counts[table]
+			entry.Delta = &delta
+		}
+		tables = append(tables, entry)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Table < tables[j].Table })
+
+	response := map[string]interface{}{
+		"taken_at": current.takenAt,
+		"tables":   tables,
+		"note":     "Row counts are estimates from pg_stat_user_tables (n_live_tup)",
+	}
+	if previous != nil {
+		var dropped []string
+		for table := range previous.counts {
+			if _, ok := current.counts[table]; !ok {
+				dropped = append(dropped, table)
+			}
+		}
+		sort.Strings(dropped)
+		response["previous_taken_at"] = previous.takenAt
+		response["dropped_tables"] = dropped
+	}
+
+	responseJSON, _ := json.Marshal(response)
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRowCountSnapshotDelta(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s, "CREATE TABLE "+schema+".growing (id int)")
+	table := schema + ".growing"
+
+	type snapshot struct {
+		Tables []TableRowCount `json:"tables"`
+	}
+	find := func(snap snapshot) *TableRowCount {
+		for i := range snap.Tables {
+			if snap.Tables[i].Table == table {
+				return &snap.Tables[i]
+			}
+		}
+		return nil
+	}
+
+	var first snapshot
+	callToolJSON(t, s.RowCountSnapshot, nil, &first)
+	if entry := find(first); entry == nil || entry.Delta != nil {
+		t.Fatalf("first snapshot entry = %+v, want %s without a delta", entry, table)
+	}
+
+	mustExec(t, s, "INSERT INTO "+table+" SELECT generate_series(1, 5)")
+
+	// The statistics collector reports inserts asynchronously, so take
+	// snapshots until they show up and add the deltas up.
+	var total int64
+	deadline := time.Now().Add(5 * time.Second)
+	for total < 5 && time.Now().Before(deadline) {
+		var next snapshot
+		callToolJSON(t, s.RowCountSnapshot, nil, &next)
+		entry := find(next)
+		if entry == nil || entry.Delta == nil {
+			t.Fatalf("snapshot entry = %+v, want %s with a delta", entry, table)
+		}
+		total += *entry.Delta
+		if total < 5 {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+	if total != 5 {
+		t.Errorf("delta = %d, want 5", total)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"strings"
+)
+
+// stripSQLComments removes -- line comments and /* */ block comments from
+// query, leaving string literals, quoted identifiers and dollar-quoted bodies
+// untouched. Each comment is replaced by a single space so that tokens on
+// either side of it stay separated, which is how Postgres lexes them.
+func stripSQLComments(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	last := 0
+	for _, c := range sqlComments(query) {
+		b.WriteString(query[last:c.start])
+		b.WriteByte(' ')
+		last = c.end
+	}
+	b.WriteString(query[last:])
+	return b.String()
+}
+
+// span is a byte range [start, end) of a query.
+type span struct {
+	start, end int
+}
+
+// sqlComments returns the byte ranges of the comments in query, in order.
+func sqlComments(query string) []span {
+	var comments []span
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			start := i
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			comments = append(comments, span{start, i})
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			// Block comments nest in Postgres.
+			start := i
+			depth := 0
+			for i < len(query) {
+				if query[i] == '/' && i+1 < len(query) && query[i+1] == '*' {
+					depth++
+					i += 2
+				} else if query[i] == '*' && i+1 < len(query) && query[i+1] == '/' {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+			comments = append(comments, span{start, i})
+		case c == '\'' || c == '"':
+			i = skipQuoted(query, i, c)
+		case c == '$':
+			i = skipDollarQuoted(query, i)
+		default:
+			i++
+		}
+	}
+	return comments
+}
+
+// trimTerminator removes comments and any trailing semicolons from query so
+// it can be embedded in a larger statement, e.g. as a subquery.
+func trimTerminator(query string) string {
+	return strings.TrimRight(stripSQLComments(query), " \t\r\n;")
+}
+
+// hasStackedStatements reports whether query holds more than one statement,
+// i.e. a semicolon outside comments, quotes and dollar-quoted strings that is
+// followed by anything other than whitespace or further semicolons.
+func hasStackedStatements(query string) bool {
+	query = stripSQLComments(query)
+	for i := 0; i < len(query); {
+		switch c := query[i]; c {
+		case '\'', '"':
+			i = skipQuoted(query, i, c)
+		case '$':
+			i = skipDollarQuoted(query, i)
+		case ';':
+			return strings.TrimLeft(query[i:], " \t\r\n;") != ""
+		default:
+			i++
+		}
+	}
+	return false
+}
+
+// skipQuoted returns the index just past the quoted section starting at
+// query[start], where quote is ' or ". A doubled quote inside the section is
+// an escaped quote, and so is a backslash-escaped one inside an E'...'
+// escape string. An unterminated section runs to the end of the query.
+func skipQuoted(query string, start int, quote byte) int {
+	escapes := quote == '\'' && isEscapeString(query, start)
+	i := start + 1
+	for i < len(query) {
+		switch {
+		case escapes && query[i] == '\\':
+			i += 2
+			continue
+		case query[i] == quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return len(query)
+}
+
+// isEscapeString reports whether the quote at query[start] opens an E'...'
+// escape string, i.e. follows an E that is not the end of a longer word.
+func isEscapeString(query string, start int) bool {
+	if start == 0 || (query[start-1] != 'e' && query[start-1] != 'E') {
+		return false
+	}
+	return start == 1 || !isIdentifierChar(query[start-2])
+}
+
+// skipDollarQuoted returns the index just past a $tag$...$tag$ string
+// starting at query[start]. If query[start] does not open a dollar quote
+// (for example a $1 parameter placeholder) it returns start+1.
+func skipDollarQuoted(query string, start int) int {
+	end := start + 1
+	for end < len(query) && isDollarTagChar(query[end]) {
+		end++
+	}
+	if end >= len(query) || query[end] != '$' {
+		return start + 1
+	}
+	if end > start+1 && query[start+1] >= '0' && query[start+1] <= '9' {
+		return start + 1
+	}
+
+	tag := query[start : end+1]
+	if closing := strings.Index(query[end+1:], tag); closing >= 0 {
+		return end + 1 + closing + len(tag)
+	}
+	return len(query)
+}
+
+func isDollarTagChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// isIdentifierChar reports whether c can continue an unquoted identifier.
+func isIdentifierChar(c byte) bool {
+	return isDollarTagChar(c) || c == '$'
+}
+
+// topLevelTokens returns the lowercased words of query that are outside
+// comments, quoted sections and parentheses, in order. Each parenthesized
+// group at the top level appears as a single "(" token, so a function call
+// shows up as its name followed by "(".
+func topLevelTokens(query string) []string {
+	query = stripSQLComments(query)
+
+	var tokens []string
+	depth := 0
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			i = skipQuoted(query, i, c)
+		case c == '$':
+			i = skipDollarQuoted(query, i)
+		case c == '(':
+			if depth == 0 {
+				tokens = append(tokens, "(")
+			}
+			depth++
+			i++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+			i++
+		case isDollarTagChar(c):
+			end := i + 1
+			for end < len(query) && isDollarTagChar(query[end]) {
+				end++
+			}
+			if depth == 0 {
+				tokens = append(tokens, strings.ToLower(query[i:end]))
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// calledFunctions returns the lowercased names of the functions query calls,
+// i.e. every word or quoted identifier outside comments and string literals
+// that is followed by "(". Schema qualifiers are dropped. Some of the names
+// may be keywords or type names, e.g. "in" or "numeric".
+func calledFunctions(query string) []string {
+	query = stripSQLComments(query)
+
+	var names []string
+	seen := make(map[string]bool)
+	// last is the identifier just before i, if only whitespace followed it.
+	last := ""
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == '\'':
+			i = skipQuoted(query, i, c)
+			last = ""
+		case c == '"':
+			end := skipQuoted(query, i, c)
+			if end-1 > i+1 {
+				last = strings.ReplaceAll(query[i+1:end-1], `""`, `"`)
+			}
+			i = end
+		case c == '$':
+			i = skipDollarQuoted(query, i)
+			last = ""
+		case isDollarTagChar(c):
+			end := i + 1
+			for end < len(query) && isIdentifierChar(query[end]) {
+				end++
+			}
+			last = strings.ToLower(query[i:end])
+			i = end
+		case c == '(':
+			if last != "" && !seen[last] {
+				seen[last] = true
+				names = append(names, last)
+			}
+			last = ""
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		default:
+			last = ""
+			i++
+		}
+	}
+	return names
+}
+
+// columnRefs returns the qualified column references in expr, an
+// expression as EXPLAIN VERBOSE prints it, as [qualifier, column] pairs,
+// e.g. p.ssn in "upper(p.ssn)". Quoted names are unquoted; qualified
+// function names, followed by "(", are skipped.
+func columnRefs(expr string) [][2]string {
+	var refs [][2]string
+	var parts []string
+	flush := func(next byte) {
+		if len(parts) >= 2 && next != '(' {
+			refs = append(refs, [2]string{parts[len(parts)-2], parts[len(parts)-1]})
+		}
+		parts = nil
+	}
+
+	// afterDot is set when a name may continue the current dotted one.
+	afterDot := false
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '\'':
+			flush(c)
+			afterDot = false
+			i = skipQuoted(expr, i, c)
+		case c == '"' || isDollarTagChar(c):
+			if !afterDot {
+				flush(c)
+			}
+			afterDot = false
+			if c == '"' {
+				end := skipQuoted(expr, i, c)
+				parts = append(parts, strings.ReplaceAll(expr[i+1:max(end-1, i+1)], `""`, `"`))
+				i = end
+				continue
+			}
+			end := i + 1
+			for end < len(expr) && isIdentifierChar(expr[end]) {
+				end++
+			}
+			parts = append(parts, expr[i:end])
+			i = end
+		case c == '.' && len(parts) > 0 && !afterDot:
+			afterDot = true
+			i++
+		default:
+			flush(c)
+			afterDot = false
+			i++
+		}
+	}
+	flush(0)
+	return refs
+}
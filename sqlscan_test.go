@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHasStackedStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  bool
+	}{
+		{"single statement", "SELECT 1", false},
+		{"trailing semicolon", "SELECT 1;", false},
+		{"trailing semicolons and whitespace", "SELECT 1 ;; \n", false},
+		{"second statement", "SELECT 1; DELETE FROM t", true},
+		{"semicolon in string", "SELECT ';'", false},
+		{"doubled quote in string", "SELECT 'it''s; fine'", false},
+		{"semicolon in quoted identifier", `SELECT 1 AS "a;b"`, false},
+		{"semicolon in dollar quote", "SELECT $$;$$", false},
+		{"semicolon in tagged dollar quote", "SELECT $body$ x; y $body$", false},
+		{"parameter placeholder", "SELECT $1; SELECT 2", true},
+		{"semicolon in line comment", "SELECT 1 -- ; DROP TABLE t", false},
+		{"semicolon in block comment", "SELECT 1 /* ; DROP TABLE t */", false},
+		{"escaped quote in E string", `SELECT E'\''; SELECT pg_sleep(1)`, true},
+		{"lower-case e string", `select e'\''; select pg_sleep(1)`, true},
+		{"backslash before closing quote in E string", `SELECT E'\\'; SELECT 2`, true},
+		{"semicolon inside E string", `SELECT E'\'; SELECT 2'`, false},
+		{"backslash in standard string", `SELECT '\'; SELECT 2`, true},
+		{"word ending in e before string", `SELECT type'\'; SELECT 2`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasStackedStatements(tt.query); got != tt.want {
+				t.Errorf("hasStackedStatements(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSafeQueryStackedStatements(t *testing.T) {
+	s := &PostgresServer{}
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{"SELECT 1;", false},
+		{"SELECT 1; DELETE FROM t", true},
+		{"SELECT ';'", false},
+		{`SELECT E'\''; SELECT pg_sleep(1)`, true},
+	}
+	for _, tt := range tests {
+		err := s.isSafeQuery(tt.query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("isSafeQuery(%q) = %v, want error: %v", tt.query, err, tt.wantErr)
+		}
+	}
+}
+
+func TestStripSQLComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"line comment", "SELECT 1 -- DROP TABLE x", "SELECT 1  "},
+		{"line comment before next line", "SELECT 1 -- x\nFROM t", "SELECT 1  \nFROM t"},
+		{"block comment", "SELECT /* x */ 1", "SELECT   1"},
+		{"block comment joining words", "SEL/**/ECT 1", "SEL ECT 1"},
+		{"nested block comment", "SELECT /* a /* b */ c */ 1", "SELECT   1"},
+		{"unterminated block comment", "SELECT 1 /* x", "SELECT 1  "},
+		{"dashes in string", "SELECT '--' AS a", "SELECT '--' AS a"},
+		{"block comment in string", "SELECT '/* x */'", "SELECT '/* x */'"},
+		{"dashes in quoted identifier", `SELECT 1 AS "a--b"`, `SELECT 1 AS "a--b"`},
+		{"dashes in dollar quote", "SELECT $$ -- x $$", "SELECT $$ -- x $$"},
+		{"block comment in tagged dollar quote", "SELECT $f$ /* x */ $f$ /* y */", "SELECT $f$ /* x */ $f$  "},
+		{"E string with escaped quote", `SELECT E'\'' -- x`, `SELECT E'\''  `},
+		{"dashes in E string", `SELECT E'\' -- x' -- y`, `SELECT E'\' -- x'  `},
+		{"standard string ending in backslash", `SELECT '\' -- x`, `SELECT '\'  `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripSQLComments(tt.query); got != tt.want {
+				t.Errorf("stripSQLComments(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSafeQueryComments(t *testing.T) {
+	s := &PostgresServer{}
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{"SELECT 1 -- DROP TABLE x", false},
+		{"/* */ DELETE FROM t", true},
+		{"/* SELECT */ DELETE FROM t", true},
+		{"-- SELECT\nDELETE FROM t", true},
+		{"/* a /* nested */ SELECT */ DELETE FROM t", true},
+		{"SELECT $$ -- $$; DELETE FROM t", true},
+		{`SELECT E'\'' -- '; DELETE FROM t`, false},
+	}
+	for _, tt := range tests {
+		err := s.isSafeQuery(tt.query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("isSafeQuery(%q) = %v, want error: %v", tt.query, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIsSafeQueryEmpty(t *testing.T) {
+	s := &PostgresServer{}
+	for _, query := range []string{"", "   \n\t", "-- just a comment", "/* block */", "-- a\n/* b */\n"} {
+		if err := s.isSafeQuery(query); !errors.Is(err, errEmptyQuery) {
+			t.Errorf("isSafeQuery(%q) = %v, want %v", query, err, errEmptyQuery)
+		}
+	}
+}
+
+func TestCalledFunctions(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT 1", nil},
+		{"SELECT lower(name), count (*) FROM users", []string{"lower", "count"}},
+		{`SELECT pg_catalog.query_to_xml('select f(1) from t', true, false, '')`, []string{"query_to_xml"}},
+		{`SELECT "MyFunc"(1), myfunc(2), MyFunc(3)`, []string{"MyFunc", "myfunc"}},
+		{"SELECT $$ g(1) $$, $1 -- h(2)\n, /* k(3) */ x FROM t", nil},
+		{"SELECT a FROM t WHERE a IN (1, 2)", []string{"in"}},
+	}
+	for _, tt := range tests {
+		if got := calledFunctions(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("calledFunctions(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestColumnRefs(t *testing.T) {
+	tests := []struct {
+		expr string
+		want [][2]string
+	}{
+		{"people.ssn", [][2]string{{"people", "ssn"}}},
+		{"(p.ssn || 'a.b'::text)", [][2]string{{"p", "ssn"}}},
+		{`upper(p."SSN"), pg_catalog.lower(q.name)`, [][2]string{{"p", "SSN"}, {"q", "name"}}},
+		{"count(*)", nil},
+		{"public.people.ssn", [][2]string{{"people", "ssn"}}},
+	}
+	for _, tt := range tests {
+		if got := columnRefs(tt.expr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("columnRefs(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHTunnelConfig describes a bastion host that database connections are
+// tunneled through
+type SSHTunnelConfig struct {
+	// Host is the bastion address as host or host:port (port 22 by default).
+	Host string `json:"host"`
+	User string `json:"user"`
+	// KeyFile is the path of the private key used to authenticate.
+	KeyFile string `json:"key_file"`
+	// KnownHostsFile is used to verify the bastion's host key.
+	KnownHostsFile string `json:"known_hosts_file"`
+}
+
+// sshDialer opens database connections through an SSH client connection. Its
+// DialContext is used as the pgx DialFunc, so Postgres is dialed via the
+// bastion instead of directly. A dropped SSH connection is re-established on
+// the next dial.
+type sshDialer struct {
+	addr   string
+	config *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+func newSSHDialer(cfg SSHTunnelConfig) (*sshDialer, error) {
+	key, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key %s: %w", cfg.KeyFile, err)
+	}
+	hostKeyCallback, err := knownhosts.New(cfg.KnownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH known hosts: %w", err)
+	}
+
+	addr := cfg.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	d := &sshDialer{
+		addr: addr,
+		config: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         10 * time.Second,
+		},
+	}
+	if _, err := d.sshClient(nil); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// sshClient returns the current SSH client, connecting if there is none.
+// Passing the client that just failed forces a reconnect, unless another
+// caller has already replaced it.
+func (d *sshDialer) sshClient(failed *ssh.Client) (*ssh.Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client != nil && d.client != failed {
+		return d.client, nil
+	}
+	if d.client != nil {
+		d.client.Close()
+		d.client = nil
+	}
+
+	client, err := ssh.Dial("tcp", d.addr, d.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH host %s: %w", d.addr, err)
+	}
+	d.client = client
+	return client, nil
+}
+
+// DialContext dials address through the bastion.
+func (d *sshDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := d.sshClient(nil)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, address)
+	if err == nil {
+		return conn, nil
+	}
+
+	// The SSH connection may have dropped; reconnect once and retry.
+	client, reconnectErr := d.sshClient(client)
+	if reconnectErr != nil {
+		return nil, fmt.Errorf("failed to dial %s through SSH tunnel: %w", address, err)
+	}
+	return client.DialContext(ctx, network, address)
+}
+
+// Close shuts down the SSH connection.
+func (d *sshDialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client == nil {
+		return nil
+	}
+	err := d.client.Close()
+	d.client = nil
+	return err
+}
+
+// defaultKnownHostsFile returns ~/.ssh/known_hosts, or "" if the home
+// directory is unknown.
+func defaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testBastion is an in-process SSH server that forwards direct-tcpip
+// channels, recording every address it was asked to reach.
+type testBastion struct {
+	addr    string
+	hostKey ssh.Signer
+
+	mu          sync.Mutex
+	connections int
+	forwarded   []string
+}
+
+func newTestBastion(t *testing.T, clientKey ssh.PublicKey) *testBastion {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	b := &testBastion{addr: listener.Addr().String(), hostKey: hostKey}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn, config)
+		}
+	}()
+	return b
+}
+
+func (b *testBastion) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	b.mu.Lock()
+	b.connections++
+	b.mu.Unlock()
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if newChannel.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+			newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip is supported")
+			continue
+		}
+		addr := net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port)))
+		b.mu.Lock()
+		b.forwarded = append(b.forwarded, addr)
+		b.mu.Unlock()
+
+		upstream, err := net.Dial("tcp", addr)
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			io.Copy(channel, upstream)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+// writeSSHFiles writes a private key and a known_hosts file trusting
+// hostKey for addr, returning their paths.
+func writeSSHFiles(t *testing.T, clientPriv ed25519.PrivateKey, addr string, hostKey ssh.PublicKey) (keyFile, knownHostsFile string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile = filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	knownHostsFile = filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{addr}, hostKey)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return keyFile, knownHostsFile
+}
+
+func TestSSHDialerDialsThroughBastion(t *testing.T) {
+	// The "database" only answers through the tunnel.
+	db, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	go func() {
+		for {
+			conn, err := db.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("hello from db"))
+			conn.Close()
+		}
+	}()
+
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshClientPub, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bastion := newTestBastion(t, sshClientPub)
+	keyFile, knownHostsFile := writeSSHFiles(t, clientPriv, bastion.addr, bastion.hostKey.PublicKey())
+
+	dialer, err := newSSHDialer(SSHTunnelConfig{
+		Host:           bastion.addr,
+		User:           "tunnel",
+		KeyFile:        keyFile,
+		KnownHostsFile: knownHostsFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dialer.Close()
+
+	read := func() string {
+		t.Helper()
+		conn, err := dialer.DialContext(context.Background(), "tcp", db.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		got, _ := io.ReadAll(conn)
+		return string(got)
+	}
+
+	if got := read(); got != "hello from db" {
+		t.Errorf("read %q through the tunnel, want the database greeting", got)
+	}
+
+	// A dropped SSH connection is re-established on the next dial.
+	dialer.client.Close()
+	if got := read(); got != "hello from db" {
+		t.Errorf("read %q after reconnecting, want the database greeting", got)
+	}
+
+	bastion.mu.Lock()
+	defer bastion.mu.Unlock()
+	if bastion.connections != 2 {
+		t.Errorf("bastion saw %d SSH connections, want 2", bastion.connections)
+	}
+	if strings.Join(bastion.forwarded, " ") != db.Addr().String()+" "+db.Addr().String() {
+		t.Errorf("bastion forwarded to %v, want the database address twice", bastion.forwarded)
+	}
+}
+
+func TestSSHDialerRejectsUnknownHostKey(t *testing.T) {
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshClientPub, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bastion := newTestBastion(t, sshClientPub)
+
+	// Trust some other key for the bastion's address.
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ssh.NewPublicKey(otherPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile, knownHostsFile := writeSSHFiles(t, clientPriv, bastion.addr, otherKey)
+
+	_, err = newSSHDialer(SSHTunnelConfig{
+		Host:           bastion.addr,
+		User:           "tunnel",
+		KeyFile:        keyFile,
+		KnownHostsFile: knownHostsFile,
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to connect to SSH host") {
+		t.Errorf("newSSHDialer() = %v, want a host key failure", err)
+	}
+}
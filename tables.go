@@ -0,0 +1,555 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Batch sizes accepted by iterate_table
+const (
+	defaultBatchSize = 100
+	maxBatchSize     = 1000
+)
+
+// TableBatch is one page of rows returned by iterate_table
+type TableBatch struct {
+	QueryResult
+	LastKey interface{} `json:"last_key"`
+	HasMore bool        `json:"has_more"`
+}
+
+func (s *PostgresServer) IterateTable(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	keyColumn, err := req.RequireString("key_column")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'key_column'"), nil
+	}
+
+	quotedTable, err := quoteTableName(table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	quotedKey, err := quoteIdentifier(keyColumn)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	// Result rows are keyed by the column's name, not by how it was quoted.
+	keyName, _ := parseIdentifier(keyColumn)
+	// The last key is handed back as the cursor, which would reveal it.
+	if s.columnMasked(tableIdentifier(table), keyName) {
+		return mcp.NewToolResultError(fmt.Sprintf("Column %q is masked (DB_MASK_COLUMNS) and cannot be the key_column", keyColumn)), nil
+	}
+
+	batchSize := req.GetInt("batch_size", defaultBatchSize)
+	if batchSize < 1 || batchSize > maxBatchSize {
+		return mcp.NewToolResultError(fmt.Sprintf("batch_size must be between 1 and %d", maxBatchSize)), nil
+	}
+
+	// Keyset pagination: seek past the last key of the previous batch instead
+	// of using OFFSET, so every batch costs the same on large tables.
+	var query string
+	var args []interface{}
+	if after, ok := req.GetArguments()["after"]; ok && after != nil {
+		query = fmt.Sprintf("SELECT * FROM %s WHERE %s > $1 ORDER BY %s LIMIT %d", quotedTable, quotedKey, quotedKey, batchSize)
+		// Bind the cursor as the client sent it back: formatting it would
+		// turn a number like 1000000 into "1e+06".
+		args = append(args, after)
+	} else {
+		query = fmt.Sprintf("SELECT * FROM %s ORDER BY %s LIMIT %d", quotedTable, quotedKey, batchSize)
+	}
+
+	if err := s.checkTableAccess(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Query failed: %s", s.errorText(err))), nil
+	}
+	defer rows.Close()
+
+	result, _, err := s.scanQueryRows(ctx, rows, 0, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := TableBatch{QueryResult: *result, HasMore: result.Count == batchSize}
+	if result.Count > 0 {
+		batch.LastKey = result.Rows[result.Count-1][keyName]
+	}
+
+	response, _ := json.Marshal(batch)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// Limits accepted by value_counts
+const (
+	defaultValueCountsLimit = 10
+	maxValueCountsLimit     = 1000
+)
+
+func (s *PostgresServer) ValueCounts(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	column, err := req.RequireString("column")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'column'"), nil
+	}
+
+	quotedTable, err := quoteTableName(table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	quotedColumn, err := quoteIdentifier(column)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	limit := req.GetInt("limit", defaultValueCountsLimit)
+	if limit < 1 || limit > maxValueCountsLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("limit must be between 1 and %d", maxValueCountsLimit)), nil
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s AS value, count(*) AS count FROM %s GROUP BY %s ORDER BY count(*) DESC LIMIT %d",
+		quotedColumn, quotedTable, quotedColumn, limit)
+
+	if err := s.checkTableAccess(ctx, query); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queryContext(ctx, query)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Query failed: %s", s.errorText(err))), nil
+	}
+	defer rows.Close()
+
+	// The column is renamed to value, so maskedColumns would not see it.
+	var masked map[string]bool
+	if columnName, _ := parseIdentifier(column); s.columnMasked(tableIdentifier(table), columnName) {
+		masked = map[string]bool{"value": true}
+	}
+	result, _, err := scanRowsLimit(rows, 0, masked)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+func (s *PostgresServer) ColumnStats(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	column, err := req.RequireString("column")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'column'"), nil
+	}
+
+	// A qualified "schema.table" name takes precedence over the schema
+	// parameter.
+	schema := req.GetString("schema", defaultSchema)
+	if strings.Contains(table, ".") {
+		schema, table, err = splitTableName(table)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	if req.GetBool("estimate", false) {
+		return s.estimatedColumnStats(ctx, schema, table, column)
+	}
+
+	quotedSchema, err := quoteIdentifier(schema)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	quotedTable, err := quoteIdentifier(table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	quotedColumn, err := quoteIdentifier(column)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	query := fmt.Sprintf(`
+        SELECT count(*) AS total_rows,
+               count(%[1]s) AS non_null,
+               count(DISTINCT %[1]s) AS distinct_count,
+               min(%[1]s) AS min,
+               max(%[1]s) AS max
+        FROM %[2]s.%[3]s`, quotedColumn, quotedSchema, quotedTable)
+
+	if err := s.checkTableAccess(ctx, query); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queryContext(ctx, query)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Query failed: %s", s.errorText(err))), nil
+	}
+	defer rows.Close()
+
+	// min and max are values of the column; the counts reveal none.
+	var masked map[string]bool
+	tableName, _ := parseIdentifier(table)
+	if columnName, _ := parseIdentifier(column); s.columnMasked(tableName, columnName) {
+		masked = map[string]bool{"min": true, "max": true}
+	}
+	result, _, err := scanRowsLimit(rows, 0, masked)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := result.Rows[0]
+	total, _ := stats["total_rows"].(int64)
+	nonNull, _ := stats["non_null"].(int64)
+	stats["null_fraction"] = 0.0
+	if total > 0 {
+		stats["null_fraction"] = float64(total-nonNull) / float64(total)
+	}
+
+	response, _ := json.Marshal(stats)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// estimatedColumnStats reads column statistics from pg_stats, as gathered
+// by the last ANALYZE, instead of scanning the table.
+func (s *PostgresServer) estimatedColumnStats(ctx context.Context, schema, table, column string) (*mcp.CallToolResult, error) {
+	for _, name := range []*string{&schema, &table, &column} {
+		ident, err := parseIdentifier(*name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		*name = ident
+	}
+
+	var rowEstimate, nullFrac, nDistinct float64
+	err := s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT c.reltuples, st.null_frac, st.n_distinct
+            FROM pg_catalog.pg_stats st
+            JOIN pg_catalog.pg_namespace n ON n.nspname = st.schemaname
+            JOIN pg_catalog.pg_class c ON c.relnamespace = n.oid AND c.relname = st.tablename
+            WHERE st.schemaname = $1 AND st.tablename = $2 AND st.attname = $3
+        `, schema, table, column).Scan(&rowEstimate, &nullFrac, &nDistinct)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return mcp.NewToolResultError(fmt.Sprintf(
+			"No statistics for %s.%s.%s; run ANALYZE on the table or omit estimate", schema, table, column)), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read column statistics: %w", err)
+	}
+
+	// A negative n_distinct is a fraction of the row count, used when the
+	// number of distinct values grows with the table.
+	distinct := nDistinct
+	if distinct < 0 {
+		distinct = -distinct * rowEstimate
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"estimated":      true,
+		"total_rows":     int64(rowEstimate),
+		"null_fraction":  nullFrac,
+		"distinct_count": int64(distinct),
+	})
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// Limits accepted by sample_rows
+const (
+	defaultSampleRowsLimit = 10
+	maxSampleRowsLimit     = 100
+)
+
+func (s *PostgresServer) SampleRows(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	schema := req.GetString("schema", defaultSchema)
+
+	quotedSchema, err := quoteIdentifier(schema)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	quotedTable, err := quoteIdentifier(table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	limit := req.GetInt("limit", defaultSampleRowsLimit)
+	if limit < 1 {
+		return mcp.NewToolResultError("limit must be at least 1"), nil
+	}
+	if limit > maxSampleRowsLimit {
+		limit = maxSampleRowsLimit
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s.%s LIMIT %d", quotedSchema, quotedTable, limit)
+
+	if err := s.checkTableAccess(ctx, query); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queryContext(ctx, query)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Query failed: %s", s.errorText(err))), nil
+	}
+	defer rows.Close()
+
+	result, _, err := s.scanQueryRows(ctx, rows, 0, query)
+	if err != nil {
+		return nil, err
+	}
+	result.limitColumns(s.opts.MaxColumns)
+	recordRowCount(ctx, result.Count)
+
+	response, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// cursorColumnTypes are the column types changes_since accepts as a cursor:
+// monotonic ids and timestamps.
+var cursorColumnTypes = map[string]bool{
+	"smallint":                    true,
+	"integer":                     true,
+	"bigint":                      true,
+	"numeric":                     true,
+	"date":                        true,
+	"timestamp without time zone": true,
+	"timestamp with time zone":    true,
+}
+
+// ChangeSet is the page of rows returned by changes_since. NextCursor is the
+// last row's cursor value, or, when the cursor column is not unique,
+// [cursor value, primary key values...] so that rows tying on the cursor
+// value are not skipped.
+type ChangeSet struct {
+	QueryResult
+	NextCursor interface{} `json:"next_cursor"`
+	HasMore    bool        `json:"has_more"`
+}
+
+func (s *PostgresServer) ChangesSince(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	table, err := req.RequireString("table")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'table'"), nil
+	}
+	cursorColumn, err := req.RequireString("cursor_column")
+	if err != nil {
+		return mcp.NewToolResultError("Missing required parameter 'cursor_column'"), nil
+	}
+	since, ok := req.GetArguments()["since"]
+	if !ok || since == nil {
+		return mcp.NewToolResultError("Missing required parameter 'since'"), nil
+	}
+
+	quotedTable, err := quoteTableName(table)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	quotedCursor, err := quoteIdentifier(cursorColumn)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	// The catalog and result rows use the column's name, not how it was
+	// quoted.
+	cursorName, _ := parseIdentifier(cursorColumn)
+	// The next cursor is the last row's value, which would reveal it.
+	if s.columnMasked(tableIdentifier(table), cursorName) {
+		return mcp.NewToolResultError(fmt.Sprintf("Column %q is masked (DB_MASK_COLUMNS) and cannot be the cursor_column", cursorColumn)), nil
+	}
+
+	limit := req.GetInt("limit", defaultBatchSize)
+	if limit < 1 || limit > maxBatchSize {
+		return mcp.NewToolResultError(fmt.Sprintf("limit must be between 1 and %d", maxBatchSize)), nil
+	}
+
+	var cursorType string
+	err = s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT pg_catalog.format_type(a.atttypid, NULL)
+            FROM pg_catalog.pg_attribute a
+            WHERE a.attrelid = $1::regclass AND a.attname = $2
+              AND a.attnum > 0 AND NOT a.attisdropped
+        `, quotedTable, cursorName).Scan(&cursorType)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return mcp.NewToolResultError(fmt.Sprintf("Column %q not found in table %s", cursorColumn, table)), nil
+	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to look up cursor column: %s", s.errorText(err))), nil
+	}
+	if !cursorColumnTypes[cursorType] {
+		return mcp.NewToolResultError(fmt.Sprintf(
+			"Cursor column %q has type %s; use a monotonic integer, numeric, date or timestamp column", cursorColumn, cursorType)), nil
+	}
+
+	// A cursor that is not unique is paired with the primary key, or a
+	// batch ending inside a run of equal values would skip the rest of it.
+	tiebreak, err := s.changeKey(ctx, quotedTable, cursorName)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to look up the table's keys: %s", s.errorText(err))), nil
+	}
+	if tiebreak == nil {
+		return mcp.NewToolResultError(fmt.Sprintf(
+			"Cursor column %q is not unique and table %s has no primary key to break ties; rows sharing a cursor value could be skipped", cursorColumn, table)), nil
+	}
+	for _, column := range tiebreak {
+		if s.columnMasked(tableIdentifier(table), column) {
+			return mcp.NewToolResultError(fmt.Sprintf("Primary key column %q is masked (DB_MASK_COLUMNS) and cannot be part of the cursor", column)), nil
+		}
+	}
+
+	// Bind since as sent, like iterate_table's cursor.
+	args, ok := cursorArgs(since, len(tiebreak))
+	if !ok {
+		return mcp.NewToolResultError("'since' must be a cursor value or the next_cursor returned by the previous call"), nil
+	}
+	keyColumns := []string{quotedCursor}
+	for _, column := range tiebreak {
+		keyColumns = append(keyColumns, pgx.Identifier{column}.Sanitize())
+	}
+	var query string
+	if len(args) == 1 {
+		query = fmt.Sprintf("SELECT * FROM %s WHERE %s > $1 ORDER BY %s LIMIT %d",
+			quotedTable, quotedCursor, strings.Join(keyColumns, ", "), limit)
+	} else {
+		placeholders := make([]string, len(args))
+		for i := range args {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		query = fmt.Sprintf("SELECT * FROM %s WHERE (%s) > (%s) ORDER BY %s LIMIT %d",
+			quotedTable, strings.Join(keyColumns, ", "), strings.Join(placeholders, ", "), strings.Join(keyColumns, ", "), limit)
+	}
+	if err := s.checkTableAccess(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Query failed: %s", s.errorText(err))), nil
+	}
+	defer rows.Close()
+
+	result, _, err := s.scanQueryRows(ctx, rows, 0, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := ChangeSet{QueryResult: *result, NextCursor: since, HasMore: result.Count == limit}
+	if result.Count > 0 {
+		last := result.Rows[result.Count-1]
+		if len(tiebreak) == 0 {
+			changes.NextCursor = last[cursorName]
+		} else {
+			next := []interface{}{last[cursorName]}
+			for _, column := range tiebreak {
+				next = append(next, last[column])
+			}
+			changes.NextCursor = next
+		}
+	}
+
+	response, _ := json.Marshal(changes)
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// changeKey returns the columns changes_since orders by after cursorName to
+// tell rows with the same cursor value apart: none (an empty slice) when a
+// unique index covers cursorName alone, otherwise the table's primary key
+// columns other than it. It returns nil when the cursor is not unique and
+// the table has no primary key.
+func (s *PostgresServer) changeKey(ctx context.Context, quotedTable, cursorName string) ([]string, error) {
+	var unique bool
+	err := s.withConnRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `
+            SELECT EXISTS (
+                SELECT 1
+                FROM pg_catalog.pg_index i
+                JOIN pg_catalog.pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+                WHERE i.indrelid = $1::regclass AND i.indisunique AND i.indnkeyatts = 1
+                  AND i.indpred IS NULL AND a.attname = $2
+            )
+        `, quotedTable, cursorName).Scan(&unique)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unique {
+		return []string{}, nil
+	}
+
+	rows, err := s.queryContext(ctx, `
+        SELECT a.attname
+        FROM pg_catalog.pg_index i
+        JOIN pg_catalog.pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY (i.indkey)
+        WHERE i.indrelid = $1::regclass AND i.indisprimary
+        ORDER BY array_position(i.indkey::int2[], a.attnum)
+    `, quotedTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var key []string
+	hasKey := false
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		hasKey = true
+		if column != cursorName {
+			key = append(key, column)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !hasKey {
+		return nil, nil
+	}
+	if key == nil {
+		// The cursor is the whole primary key, so it is unique.
+		key = []string{}
+	}
+	return key, nil
+}
+
+// cursorArgs returns the values to bind for since, a changes_since cursor
+// with keyColumns primary key columns after the cursor value: a single
+// value, or a next_cursor array, as sent or as JSON text. It reports false
+// when since has the wrong shape.
+func cursorArgs(since interface{}, keyColumns int) ([]interface{}, bool) {
+	values, isArray := since.([]interface{})
+	if text, ok := since.(string); ok && strings.HasPrefix(text, "[") {
+		isArray = json.Unmarshal([]byte(text), &values) == nil
+	}
+	switch {
+	case !isArray:
+		return []interface{}{since}, true
+	case len(values) == 1 || len(values) == 1+keyColumns:
+		return values, true
+	default:
+		return nil, false
+	}
+}
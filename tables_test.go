@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIterateTableTwoBatches(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		`CREATE TABLE `+schema+`.items ("Key" int PRIMARY KEY, label text)`,
+		"INSERT INTO "+schema+".items SELECT g, 'item ' || g FROM generate_series(1, 5) g",
+	)
+
+	var first TableBatch
+	callToolJSON(t, s.IterateTable, map[string]interface{}{
+		"table":      schema + ".items",
+		"key_column": `"Key"`,
+		"batch_size": 3,
+	}, &first)
+	if first.Count != 3 || !first.HasMore || first.LastKey != float64(3) {
+		t.Fatalf("first batch: %d rows, has_more %v, last_key %v; want 3 rows, more, last key 3",
+			first.Count, first.HasMore, first.LastKey)
+	}
+
+	// Pass last_key back as the client would after decoding the response.
+	var second TableBatch
+	callToolJSON(t, s.IterateTable, map[string]interface{}{
+		"table":      schema + ".items",
+		"key_column": `"Key"`,
+		"batch_size": 3,
+		"after":      first.LastKey,
+	}, &second)
+	if second.Count != 2 || second.HasMore || second.LastKey != float64(5) {
+		t.Fatalf("second batch: %d rows, has_more %v, last_key %v; want 2 rows, no more, last key 5",
+			second.Count, second.HasMore, second.LastKey)
+	}
+	if second.Rows[0]["label"] != "item 4" {
+		t.Errorf("second batch starts at %v, want item 4", second.Rows[0])
+	}
+}
+
+func TestIterateTableLargeNumericCursor(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".events (id bigint PRIMARY KEY)",
+		"INSERT INTO "+schema+".events VALUES (999999), (1000000), (1000001)",
+	)
+
+	var batch TableBatch
+	callToolJSON(t, s.IterateTable, map[string]interface{}{
+		"table":      schema + ".events",
+		"key_column": "id",
+		"after":      float64(1000000),
+	}, &batch)
+	if batch.Count != 1 || batch.LastKey != float64(1000001) {
+		t.Errorf("got %d rows with last_key %v, want only 1000001", batch.Count, batch.LastKey)
+	}
+}
+
+func TestIterateTableRejectsBadIdentifiers(t *testing.T) {
+	s := &PostgresServer{}
+	for _, args := range []map[string]interface{}{
+		{"table": "items; drop table x", "key_column": "id"},
+		{"table": "items", "key_column": "id; drop table x"},
+		{"table": "items", "key_column": "id", "batch_size": maxBatchSize + 1},
+	} {
+		if text, isError := callTool(t, s.IterateTable, args); !isError {
+			t.Errorf("%v: got %q, want an error", args, text)
+		}
+	}
+}
+
+func TestValueCountsSkewed(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".orders (status text)",
+		"INSERT INTO "+schema+".orders SELECT 'shipped' FROM generate_series(1, 50)",
+		"INSERT INTO "+schema+".orders SELECT 'pending' FROM generate_series(1, 10)",
+		"INSERT INTO "+schema+".orders SELECT 'cancelled' FROM generate_series(1, 3)",
+		"INSERT INTO "+schema+".orders VALUES ('lost')",
+	)
+
+	var got QueryResult
+	callToolJSON(t, s.ValueCounts, map[string]interface{}{
+		"table":  schema + ".orders",
+		"column": "status",
+		"limit":  3,
+	}, &got)
+
+	want := []struct {
+		value string
+		count float64
+	}{{"shipped", 50}, {"pending", 10}, {"cancelled", 3}}
+	if got.Count != len(want) {
+		t.Fatalf("got %d values, want the top %d: %v", got.Count, len(want), got.Rows)
+	}
+	for i, w := range want {
+		if got.Rows[i]["value"] != w.value || got.Rows[i]["count"] != w.count {
+			t.Errorf("row %d = %v, want %s with count %v", i, got.Rows[i], w.value, w.count)
+		}
+	}
+}
+
+func TestChangesSinceAfterCursor(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		`CREATE TABLE `+schema+`.events ("Version" bigint PRIMARY KEY, updated_at timestamptz, payload text)`,
+		`INSERT INTO `+schema+`.events
+			SELECT g, timestamptz '2024-01-01 00:00:00+00' + g * interval '1 hour', 'event ' || g
+			FROM generate_series(1, 6) g`,
+	)
+
+	var got ChangeSet
+	callToolJSON(t, s.ChangesSince, map[string]interface{}{
+		"table":         schema + ".events",
+		"cursor_column": `"Version"`,
+		"since":         float64(4),
+	}, &got)
+	if got.Count != 2 || got.Rows[0]["payload"] != "event 5" || got.Rows[1]["payload"] != "event 6" {
+		t.Errorf("rows = %v, want events 5 and 6", got.Rows)
+	}
+	if got.NextCursor != float64(6) || got.HasMore {
+		t.Errorf("next_cursor = %v, has_more = %v, want 6 and no more", got.NextCursor, got.HasMore)
+	}
+
+	var byTime ChangeSet
+	callToolJSON(t, s.ChangesSince, map[string]interface{}{
+		"table":         schema + ".events",
+		"cursor_column": "updated_at",
+		"since":         "2024-01-01T05:00:00Z",
+		"limit":         1,
+	}, &byTime)
+	if byTime.Count != 1 || byTime.Rows[0]["payload"] != "event 6" || !byTime.HasMore {
+		t.Errorf("rows = %v (has_more: %v), want event 6 with a full page", byTime.Rows, byTime.HasMore)
+	}
+	// updated_at is not unique, so the cursor carries the primary key.
+	if next, ok := byTime.NextCursor.([]interface{}); !ok || len(next) != 2 || next[1] != float64(6) {
+		t.Errorf("next_cursor = %v, want [updated_at, 6]", byTime.NextCursor)
+	}
+
+	var none ChangeSet
+	callToolJSON(t, s.ChangesSince, map[string]interface{}{
+		"table":         schema + ".events",
+		"cursor_column": `"Version"`,
+		"since":         float64(6),
+	}, &none)
+	if none.Count != 0 || none.NextCursor != float64(6) {
+		t.Errorf("got %d rows with next_cursor %v, want none and the cursor unchanged", none.Count, none.NextCursor)
+	}
+}
+
+func TestChangesSinceTiedCursorValues(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		`CREATE TABLE `+schema+`.orders (id int PRIMARY KEY, updated_at timestamptz)`,
+		// Five orders share each timestamp, so every batch of two ends
+		// inside a run of equal values.
+		`INSERT INTO `+schema+`.orders
+			SELECT g, timestamptz '2024-01-01 00:00:00+00' + (g / 5) * interval '1 minute'
+			FROM generate_series(0, 14) g`,
+	)
+
+	var since interface{} = "2023-12-31T00:00:00Z"
+	var seen []float64
+	for calls := 0; calls < 20; calls++ {
+		var got ChangeSet
+		callToolJSON(t, s.ChangesSince, map[string]interface{}{
+			"table":         schema + ".orders",
+			"cursor_column": "updated_at",
+			"since":         since,
+			"limit":         2,
+		}, &got)
+		for _, row := range got.Rows {
+			seen = append(seen, row["id"].(float64))
+		}
+		if !got.HasMore {
+			break
+		}
+		// Alternate between sending the cursor back as is and as JSON text.
+		since = got.NextCursor
+		if calls%2 == 1 {
+			text, _ := json.Marshal(got.NextCursor)
+			since = string(text)
+		}
+	}
+	if len(seen) != 15 {
+		t.Fatalf("saw ids %v, want all 15 orders", seen)
+	}
+	for i, id := range seen {
+		if id != float64(i) {
+			t.Fatalf("saw ids %v, want 0 to 14 in order", seen)
+		}
+	}
+}
+
+func TestCursorArgs(t *testing.T) {
+	tests := []struct {
+		since      interface{}
+		keyColumns int
+		want       []interface{}
+		ok         bool
+	}{
+		{float64(4), 1, []interface{}{float64(4)}, true},
+		{"2024-01-01T00:00:00Z", 1, []interface{}{"2024-01-01T00:00:00Z"}, true},
+		{[]interface{}{"2024-01-01T00:00:00Z", float64(3)}, 1, []interface{}{"2024-01-01T00:00:00Z", float64(3)}, true},
+		{`["2024-01-01T00:00:00Z", 3]`, 1, []interface{}{"2024-01-01T00:00:00Z", float64(3)}, true},
+		{[]interface{}{float64(4)}, 2, []interface{}{float64(4)}, true},
+		{[]interface{}{float64(4), float64(1)}, 2, nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := cursorArgs(tt.since, tt.keyColumns)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cursorArgs(%v, %d) = %v, %v; want %v, %v", tt.since, tt.keyColumns, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestChangesSinceRejectsCursorColumn(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s, "CREATE TABLE "+schema+".events (id int, seq int UNIQUE, payload text)")
+
+	for column, want := range map[string]string{
+		"payload": "has type text",
+		"missing": "not found",
+		"id":      "no primary key",
+	} {
+		text, isError := callTool(t, s.ChangesSince, map[string]interface{}{
+			"table":         schema + ".events",
+			"cursor_column": column,
+			"since":         "x",
+		})
+		if !isError || !strings.Contains(text, want) {
+			t.Errorf("cursor_column %s: got %q, want an error containing %q", column, text, want)
+		}
+	}
+}
+
+func TestSampleRowsIdentifierCase(t *testing.T) {
+	s := newTestServer(t, ServerOptions{})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".users (id int)",
+		`CREATE TABLE `+schema+`."Users" (id int, mixed boolean)`,
+		"INSERT INTO "+schema+".users VALUES (1)",
+		`INSERT INTO `+schema+`."Users" VALUES (2, true)`,
+	)
+
+	for table, want := range map[string]float64{"users": 1, "USERS": 1, `"Users"`: 2} {
+		var got QueryResult
+		callToolJSON(t, s.SampleRows, map[string]interface{}{"schema": schema, "table": table}, &got)
+		if got.Count != 1 || got.Rows[0]["id"] != want {
+			t.Errorf("sample_rows %s = %v, want the row with id %v", table, got.Rows, want)
+		}
+	}
+}
+
+func TestColumnStatsQualifiedTable(t *testing.T) {
+	s := newTestServer(t, ServerOptions{MaskColumns: parseColumnMasks("users.email")})
+	schema := testSchema(t, s)
+	mustExec(t, s,
+		"CREATE TABLE "+schema+".users (id int, email text)",
+		"INSERT INTO "+schema+".users VALUES (1, 'a@example.com'), (2, NULL)",
+	)
+
+	for _, args := range []map[string]interface{}{
+		{"schema": schema, "table": "users", "column": "email"},
+		{"table": schema + ".users", "column": "email"},
+		{"schema": "ignored", "table": schema + `."users"`, "column": "email"},
+	} {
+		var got map[string]interface{}
+		callToolJSON(t, s.ColumnStats, args, &got)
+		if got["total_rows"] != float64(2) || got["non_null"] != float64(1) || got["max"] != "***" {
+			t.Errorf("column_stats %v = %v, want 2 rows, 1 non-null and a masked max", args, got)
+		}
+	}
+
+	if text, isError := callTool(t, s.ColumnStats, map[string]interface{}{"table": "a.b.c", "column": "id"}); !isError || !strings.Contains(text, "invalid table name") {
+		t.Errorf("column_stats a.b.c = %q, want an invalid name error", text)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"unicode"
+)
+
+// tenantSetting is the session variable row-level security policies read the
+// current tenant from, e.g. USING (tenant_id = current_setting('app.current_tenant')).
+const tenantSetting = "app.current_tenant"
+
+// setTenantSQL sets a session variable for the current transaction only.
+const setTenantSQL = "SELECT set_config($1, $2, true)"
+
+// maxTenantLength bounds tenant identifiers supplied by clients.
+const maxTenantLength = 256
+
+// validateTenant rejects tenant identifiers that are empty, oversized or
+// contain control characters. The value is always bound as a parameter, so
+// this guards against garbage rather than injection.
+func validateTenant(tenant string) error {
+	if tenant == "" || len(tenant) > maxTenantLength {
+		return fmt.Errorf("tenant must be between 1 and %d bytes", maxTenantLength)
+	}
+	for _, r := range tenant {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("tenant must not contain control characters")
+		}
+	}
+	return nil
+}
+
+// beginTenantTx starts a read-only transaction with app.current_tenant set to
+// tenant.
+// set_config(..., true) is the bindable form of SET LOCAL: the setting lasts
+// until the transaction ends, so it never leaks to other users of the pooled
+// connection. Callers must roll the transaction back when done.
+func (s *PostgresServer) beginTenantTx(ctx context.Context, tenant string) (*sql.Tx, error) {
+	if err := validateTenant(tenant); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.beginReadOnlyTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, setTenantSQL, tenantSetting, tenant); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to set %s: %s", tenantSetting, s.errorText(err))
+	}
+	return tx, nil
+}
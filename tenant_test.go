@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTenant(t *testing.T) {
+	for _, tenant := range []string{"acme", "tenant-42", "Ünïcode"} {
+		if err := validateTenant(tenant); err != nil {
+			t.Errorf("validateTenant(%q) = %v, want nil", tenant, err)
+		}
+	}
+	for _, tenant := range []string{"", strings.Repeat("x", maxTenantLength+1), "a\nb", "a\x00b"} {
+		if err := validateTenant(tenant); err == nil {
+			t.Errorf("validateTenant(%q) = nil, want an error", tenant)
+		}
+	}
+}
+
+func TestExecuteQueryTenantRowLevelSecurity(t *testing.T) {
+	setup := newTestServer(t, ServerOptions{})
+	// Superusers bypass row-level security, so the queries run as a role
+	// without it.
+	role := fmt.Sprintf("pgmcp_tenant_%d", time.Now().UnixNano())
+	mustExec(t, setup, "CREATE ROLE "+role+" NOLOGIN")
+	t.Cleanup(func() { setup.db.Exec("DROP ROLE " + role) })
+
+	schema := testSchema(t, setup)
+	mustExec(t, setup,
+		"CREATE TABLE "+schema+".docs (tenant text, title text)",
+		"INSERT INTO "+schema+".docs VALUES ('acme', 'acme plan'), ('acme', 'acme budget'), ('globex', 'globex plan')",
+		"ALTER TABLE "+schema+".docs ENABLE ROW LEVEL SECURITY",
+		"CREATE POLICY tenant_isolation ON "+schema+".docs USING (tenant = current_setting('app.current_tenant', true))",
+		"GRANT USAGE ON SCHEMA "+schema+" TO "+role,
+		"GRANT SELECT ON "+schema+".docs TO "+role,
+	)
+	s := newTestServerWithParams(t, ServerOptions{}, "role="+role)
+
+	var setting QueryResult
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{
+		"query":  "SELECT current_setting('app.current_tenant') AS tenant",
+		"tenant": "acme",
+	}, &setting)
+	if setting.Count != 1 || setting.Rows[0]["tenant"] != "acme" {
+		t.Errorf("app.current_tenant = %v, want acme", setting.Rows)
+	}
+
+	for tenant, want := range map[string]int{"acme": 2, "globex": 1, "initech": 0} {
+		var got QueryResult
+		callToolJSON(t, s.ExecuteQuery, map[string]interface{}{
+			"query":  "SELECT tenant, title FROM " + schema + ".docs",
+			"tenant": tenant,
+		}, &got)
+		if got.Count != want {
+			t.Errorf("tenant %s sees %d rows, want %d", tenant, got.Count, want)
+		}
+		for _, row := range got.Rows {
+			if row["tenant"] != tenant {
+				t.Errorf("tenant %s sees %v", tenant, row)
+			}
+		}
+	}
+
+	var none QueryResult
+	callToolJSON(t, s.ExecuteQuery, map[string]interface{}{"query": "SELECT * FROM " + schema + ".docs"}, &none)
+	if none.Count != 0 {
+		t.Errorf("without a tenant %d rows are visible, want 0", none.Count)
+	}
+}